@@ -8,6 +8,7 @@ import (
 	"os"
 	"os/exec"
 	"path/filepath"
+	"strings"
 	"sync"
 	"text/template"
 	"time"
@@ -64,6 +65,10 @@ func main() {
 	os.MkdirAll("results", 0755)
 
 	broker := newSSEBroker()
+	control := engine.NewControl()
+
+	// --- GET|POST /api/control --- (ajuste en vivo del run en curso)
+	mux.Handle("/api/control", control.Handler())
 
 	// --- GET /api/events ---
 	mux.HandleFunc("/api/events", func(w http.ResponseWriter, r *http.Request) {
@@ -115,6 +120,16 @@ func main() {
 
 		go func() {
 			for ev := range events {
+				if ev.Name == "ANNOTATION" {
+					fmt.Printf("📝 Run annotation: %s\n", ev.Path)
+					data, _ := json.Marshal(ev)
+					broker.broadcast(data)
+					continue
+				}
+				if ev.Name == "EVENT_STATS" && ev.Dropped > 0 {
+					fmt.Printf("⚠️ %d events were dropped (%s) — dashboard totals are partial\n", ev.Dropped, ev.Path)
+				}
+
 				// 🚫 Ignorar eventos del sistema que no son requests HTTP
 				if ev.Method == "" || ev.Method == "SYSTEM" || ev.Method == "INFO" {
 					continue
@@ -126,8 +141,11 @@ func main() {
 		}()
 
 		go func() {
-			engine.RunWithEvents(savePath, events)
+			result, err := engine.RunWithOptions(savePath, engine.Options{Control: control}, events)
 			close(events)
+			if err != nil {
+				fmt.Println("❌ Run error:", err)
+			}
 
 			end := time.Now()
 			summary := map[string]interface{}{
@@ -135,6 +153,13 @@ func main() {
 				"ended_at":   end,
 				"yaml_file":  filename,
 			}
+			if result != nil {
+				// Cambios hechos en vivo (VUs, rate, pausa) y motivo de abort
+				summary["annotations"] = result.Annotations
+				if result.Aborted != "" {
+					summary["aborted"] = result.Aborted
+				}
+			}
 			outPath := fmt.Sprintf("results/run_%s.summary.json", end.Format("2006-01-02_150405"))
 			os.WriteFile(outPath, mustJSON(summary), 0644)
 		}()
@@ -159,6 +184,10 @@ func main() {
 			return
 		}
 
+		if strings.HasSuffix(ev.Name, "EVENT_STATS") && ev.Dropped > 0 {
+			fmt.Printf("⚠️ %s dropped %d events (%s) — dashboard totals are partial\n", ev.Name, ev.Dropped, ev.Path)
+		}
+
 		// 🚫 Ignorar también eventos de sistema enviados remotamente
 		if ev.Method == "" || ev.Method == "SYSTEM" || ev.Method == "INFO" {
 			return
@@ -60,6 +60,8 @@ func main() {
 	// ---------------------------------------------------------------------
 	// RUN COMMAND
 	// ---------------------------------------------------------------------
+	var runOpts engine.Options
+	var controlAddr string
 	var runCmd = &cobra.Command{
 		Use:   "run <file>",
 		Short: "Run a Pulse scenario file",
@@ -71,12 +73,72 @@ func main() {
 				os.Exit(1)
 			}
 			fmt.Printf("🚀 Running scenario: %s\n", file)
-			if err := engine.Run(file); err != nil {
+			if controlAddr != "" {
+				runOpts.Control = engine.NewControl()
+				mux := http.NewServeMux()
+				mux.Handle("/api/control", runOpts.Control.Handler())
+				go func() {
+					if err := http.ListenAndServe(controlAddr, mux); err != nil {
+						fmt.Println("Control endpoint error:", err)
+					}
+				}()
+				fmt.Printf("🎛️ Live control at http://localhost%s/api/control\n", controlAddr)
+			}
+			if _, err := engine.RunWithOptions(file, runOpts, nil); err != nil {
 				fmt.Println("Error running scenario:", err)
 				os.Exit(1)
 			}
 		},
 	}
+	runCmd.Flags().StringVar(&runOpts.ProxyURL, "proxy", "", "Upstream proxy for the engine (http://, https:// or socks5://, credentials in the URL)")
+	runCmd.Flags().StringSliceVar(&runOpts.NoProxy, "no-proxy", nil, "Hosts, domains or CIDRs that bypass the proxy (comma separated)")
+	runCmd.Flags().StringVar(&controlAddr, "control-addr", "", "Expose the live control API (VUs, rate, pause/resume) on this address, e.g. :6565")
+
+	// ---------------------------------------------------------------------
+	// CAPACITY COMMAND
+	// ---------------------------------------------------------------------
+	var capOpts engine.CapacityOptions
+	var capacityCmd = &cobra.Command{
+		Use:   "capacity <file>",
+		Short: "Increase load step by step until the SLO breaks",
+		Args:  cobra.ExactArgs(1),
+		Run: func(cmd *cobra.Command, args []string) {
+			if _, err := engine.Capacity(args[0], capOpts); err != nil {
+				fmt.Println("Error running capacity search:", err)
+				os.Exit(1)
+			}
+		},
+	}
+	capacityCmd.Flags().IntVar(&capOpts.Start, "start", 10, "Virtual users in the first step")
+	capacityCmd.Flags().IntVar(&capOpts.Step, "step", 10, "Virtual users added at each step")
+	capacityCmd.Flags().IntVar(&capOpts.Max, "max", 200, "Maximum virtual users")
+	capacityCmd.Flags().DurationVar(&capOpts.StepDuration, "step-duration", 30*time.Second, "Duration of each step")
+	capacityCmd.Flags().DurationVar(&capOpts.MaxP95, "p95", 0, "SLO: maximum p95 latency (e.g. 500ms; 0 = not checked)")
+	capacityCmd.Flags().Float64Var(&capOpts.MaxErrorRate, "error-rate", 1, "SLO: maximum error rate in % (negative = not checked)")
+	capacityCmd.Flags().StringVar(&capOpts.ProxyURL, "proxy", "", "Upstream proxy for the engine")
+	capacityCmd.Flags().StringSliceVar(&capOpts.NoProxy, "no-proxy", nil, "Hosts, domains or CIDRs that bypass the proxy")
+
+	// ---------------------------------------------------------------------
+	// SWEEP COMMAND
+	// ---------------------------------------------------------------------
+	var sweepOpts engine.SweepOptions
+	var sweepCmd = &cobra.Command{
+		Use:   "sweep <file>",
+		Short: "Run a scenario at several concurrency levels and report scalability",
+		Args:  cobra.ExactArgs(1),
+		Run: func(cmd *cobra.Command, args []string) {
+			if _, err := engine.Sweep(args[0], sweepOpts); err != nil {
+				fmt.Println("Error running sweep:", err)
+				os.Exit(1)
+			}
+		},
+	}
+	sweepCmd.Flags().IntSliceVar(&sweepOpts.Levels, "levels", []int{10, 50, 100, 200}, "Concurrency levels (comma separated)")
+	sweepCmd.Flags().DurationVar(&sweepOpts.Duration, "duration", 0, "Duration of each run (default: the scenario duration)")
+	sweepCmd.Flags().DurationVar(&sweepOpts.Cooldown, "cooldown", 10*time.Second, "Pause between runs")
+	sweepCmd.Flags().StringVarP(&sweepOpts.Out, "out", "o", "", "Chart data JSON (default results/sweep_<timestamp>.json)")
+	sweepCmd.Flags().StringVar(&sweepOpts.ProxyURL, "proxy", "", "Upstream proxy for the engine")
+	sweepCmd.Flags().StringSliceVar(&sweepOpts.NoProxy, "no-proxy", nil, "Hosts, domains or CIDRs that bypass the proxy")
 
 	// ---------------------------------------------------------------------
 	// RECORD COMMAND (CLI)
@@ -303,7 +365,7 @@ func main() {
 		},
 	}
 
-	rootCmd.AddCommand(runCmd, recordCmd, serveCmd)
+	rootCmd.AddCommand(runCmd, capacityCmd, sweepCmd, recordCmd, serveCmd)
 	if err := rootCmd.Execute(); err != nil {
 		fmt.Println("Error:", err)
 		os.Exit(1)
@@ -27,8 +27,14 @@ func main() {
 	fmt.Printf("⚙️ Node %d/%d executing scenario: %s\n", *nodeID, *totalNodes, *yamlPath)
 
 	events := make(chan engine.Event, 100)
+	var droppedEvents int
+	done := make(chan struct{})
 	go func() {
+		defer close(done)
 		for ev := range events {
+			if ev.Name == "EVENT_STATS" {
+				droppedEvents = ev.Dropped
+			}
 			// añade metadata del nodo al nombre del evento (opcional)
 			ev.Name = fmt.Sprintf("[node-%d] %s", *nodeID, ev.Name)
 			if reportURL != "" {
@@ -46,6 +52,7 @@ func main() {
 		log.Fatalf("❌ Node %d failed: %v", *nodeID, err)
 	}
 	close(events)
+	<-done
 
 	fmt.Printf("✅ Node %d finished successfully!\n", *nodeID)
 
@@ -62,6 +69,7 @@ func main() {
 				"avg_ms":   0.0,
 				"p95_ms":   0.0,
 			},
+			"events_dropped": droppedEvents,
 		}
 		payload, _ := json.Marshal(summary)
 		resp, err := http.Post(reportURL, "application/json", bytes.NewBuffer(payload))
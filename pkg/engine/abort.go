@@ -0,0 +1,220 @@
+package engine
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"time"
+)
+
+// -------------------------------------------------------------
+// Políticas ante errores y condiciones de aborto
+// -------------------------------------------------------------
+//
+//	on_error: continue           # default del escenario
+//	abort:
+//	  error_rate: 50             # % de errores ...
+//	  window: 10s                # ... sostenido en esta ventana (default 10s)
+//	  min_requests: 20           # muestras mínimas en la ventana (default 20)
+//	  unreachable: 15s           # todos los requests sin conexión durante 15s
+//
+//	requests:
+//	  - name: login
+//	    on_error: stop_vu        # continue | skip_iteration | restart_iteration | stop_vu
+//
+// on_error se aplica cuando el step termina con error (tras los reintentos
+// y el think time del request): skip_iteration abandona el resto de la
+// iteración y sigue con la próxima, restart_iteration vuelve a empezarla
+// (respetando el pacing y con un backoff que crece con cada restart seguido,
+// de 100ms a 5s) y stop_vu retira al VU. Las iteraciones cortadas así no
+// cuentan como completadas.
+//
+// Las condiciones de abort se evalúan sobre los resultados de los últimos
+// segundos; al cumplirse se cancela el run y el motivo queda en el resumen,
+// como anotación y como error de Run. error_rate no mira los requests del
+// warm-up; unreachable sí.
+
+const (
+	OnErrorContinue = "continue"
+	OnErrorSkip     = "skip_iteration"
+	OnErrorRestart  = "restart_iteration"
+	OnErrorStopVU   = "stop_vu"
+)
+
+type Abort struct {
+	ErrorRate   float64 `yaml:"error_rate,omitempty"` // %; 0 = no se evalúa
+	Window      string  `yaml:"window,omitempty"`
+	MinRequests int     `yaml:"min_requests,omitempty"`
+	Unreachable string  `yaml:"unreachable,omitempty"` // "" = no se evalúa
+}
+
+func onErrorPolicy(scenario Scenario, reqCfg Request) (string, error) {
+	policy := reqCfg.OnError
+	if policy == "" {
+		policy = scenario.OnError
+	}
+	switch policy {
+	case "":
+		return OnErrorContinue, nil
+	case OnErrorContinue, OnErrorSkip, OnErrorRestart, OnErrorStopVU:
+		return policy, nil
+	}
+	return "", fmt.Errorf("unknown on_error %q", policy)
+}
+
+// iterOutcome: cómo terminó una iteración de un VU
+type iterOutcome int
+
+const (
+	iterCompleted   iterOutcome = iota
+	iterInterrupted             // venció el context
+	iterSkipped                 // on_error: skip_iteration
+	iterRestart                 // on_error: restart_iteration
+	iterStopVU                  // on_error: stop_vu
+)
+
+// restartBackoff: espera antes del n-ésimo restart_iteration seguido
+func restartBackoff(n int) time.Duration {
+	if n <= 0 {
+		return 0
+	}
+	if n > 6 {
+		return 5 * time.Second
+	}
+	d := 100 * time.Millisecond << (n - 1)
+	if d > 5*time.Second {
+		d = 5 * time.Second
+	}
+	return d
+}
+
+// -------------------------------------------------------------
+// Monitor de aborto
+// -------------------------------------------------------------
+
+// abortBucket: resultados de un segundo del run
+type abortBucket struct {
+	sec         int64
+	total       int // sin warm-up
+	failures    int
+	attempts    int // con warm-up
+	connectFail int
+}
+
+type abortMonitor struct {
+	start       time.Time
+	errorRate   float64
+	window      time.Duration
+	minRequests int
+	unreachable time.Duration
+	buckets     []abortBucket // anillo por segundo
+	lastErr     error         // último fallo de conexión, para el motivo
+}
+
+func newAbortMonitor(cfg *Abort, start time.Time) (*abortMonitor, error) {
+	if cfg == nil {
+		return nil, nil
+	}
+	m := &abortMonitor{start: start, errorRate: cfg.ErrorRate, window: 10 * time.Second, minRequests: 20}
+	if cfg.ErrorRate < 0 || cfg.ErrorRate > 100 {
+		return nil, fmt.Errorf("invalid abort error_rate %g", cfg.ErrorRate)
+	}
+	if cfg.Window != "" {
+		d, err := time.ParseDuration(cfg.Window)
+		if err != nil || d < time.Second {
+			return nil, fmt.Errorf("invalid abort window %q", cfg.Window)
+		}
+		m.window = d
+	}
+	if cfg.MinRequests > 0 {
+		m.minRequests = cfg.MinRequests
+	}
+	if cfg.Unreachable != "" {
+		d, err := time.ParseDuration(cfg.Unreachable)
+		if err != nil || d < time.Second {
+			return nil, fmt.Errorf("invalid abort unreachable %q", cfg.Unreachable)
+		}
+		m.unreachable = d
+	}
+	if m.errorRate == 0 && m.unreachable == 0 {
+		return nil, fmt.Errorf("abort needs error_rate and/or unreachable")
+	}
+
+	span := m.window
+	if m.unreachable > span {
+		span = m.unreachable
+	}
+	m.buckets = make([]abortBucket, int(span/time.Second)+1)
+	for i := range m.buckets {
+		m.buckets[i].sec = -1
+	}
+	return m, nil
+}
+
+// observe registra un resultado y devuelve el motivo de aborto, o ""
+func (m *abortMonitor) observe(r result, now time.Time) string {
+	if m == nil {
+		return ""
+	}
+	elapsed := now.Sub(m.start)
+	sec := int64(elapsed / time.Second)
+	b := &m.buckets[sec%int64(len(m.buckets))]
+	if b.sec != sec {
+		*b = abortBucket{sec: sec}
+	}
+	b.attempts++
+	if !r.warmUp {
+		b.total++
+	}
+	if r.err != nil {
+		if !r.warmUp {
+			b.failures++
+		}
+		if isConnectFailure(r.err) {
+			b.connectFail++
+			m.lastErr = r.err
+		}
+	}
+
+	if m.errorRate > 0 && elapsed >= m.window {
+		total, failures, _, _ := m.sum(sec, m.window)
+		if total >= m.minRequests {
+			if rate := float64(failures) / float64(total) * 100; rate > m.errorRate {
+				return fmt.Sprintf("error rate %.1f%% > %g%% over the last %s (%d/%d requests failed)",
+					rate, m.errorRate, m.window, failures, total)
+			}
+		}
+	}
+	if m.unreachable > 0 && elapsed >= m.unreachable {
+		_, _, attempts, connectFail := m.sum(sec, m.unreachable)
+		if attempts > 0 && connectFail == attempts {
+			return fmt.Sprintf("target unreachable: all %d requests in the last %s failed to connect (%v)",
+				attempts, m.unreachable, m.lastErr)
+		}
+	}
+	return ""
+}
+
+// sum agrega los buckets de los últimos span segundos hasta sec
+func (m *abortMonitor) sum(sec int64, span time.Duration) (total, failures, attempts, connectFail int) {
+	from := sec - int64(span/time.Second)
+	for _, b := range m.buckets {
+		if b.sec > from && b.sec <= sec {
+			total += b.total
+			failures += b.failures
+			attempts += b.attempts
+			connectFail += b.connectFail
+		}
+	}
+	return total, failures, attempts, connectFail
+}
+
+// isConnectFailure: el request no llegó a conectar (DNS, dial, proxy)
+func isConnectFailure(err error) bool {
+	var dnsErr *net.DNSError
+	if errors.As(err, &dnsErr) {
+		return true
+	}
+	var opErr *net.OpError
+	return errors.As(err, &opErr) && (opErr.Op == "dial" || opErr.Op == "proxyconnect")
+}
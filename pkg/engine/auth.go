@@ -0,0 +1,333 @@
+package engine
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// -------------------------------------------------------------
+// Autenticación (bloque `auth` del escenario)
+// -------------------------------------------------------------
+//
+//	auth:
+//	  type: basic            # basic | bearer | oauth2 | aws_sigv4
+//	  username: demo
+//	  password: ${DEMO_PASSWORD}
+//
+//	auth:
+//	  type: oauth2
+//	  token_url: https://idp.example.com/oauth/token
+//	  client_id: pulse
+//	  client_secret: ${CLIENT_SECRET}
+//	  scopes: [orders.read]
+//	  refresh_before: 30s    # renueva antes de que expire (a lo sumo media vida)
+//
+//	auth:
+//	  type: aws_sigv4
+//	  region: us-east-1
+//	  service: execute-api   # access_key/secret_key default: AWS_* del entorno
+//
+// Los valores admiten ${VAR} del entorno. La cabecera Authorization que
+// traiga el request grabado se reemplaza.
+
+const (
+	AuthBasic    = "basic"
+	AuthBearer   = "bearer"
+	AuthOAuth2   = "oauth2"
+	AuthAWSSigV4 = "aws_sigv4"
+)
+
+type Auth struct {
+	Type string `yaml:"type"`
+
+	// basic
+	Username string `yaml:"username,omitempty"`
+	Password string `yaml:"password,omitempty"`
+
+	// bearer
+	Token string `yaml:"token,omitempty"`
+
+	// oauth2 (client credentials)
+	TokenURL      string   `yaml:"token_url,omitempty"`
+	ClientID      string   `yaml:"client_id,omitempty"`
+	ClientSecret  string   `yaml:"client_secret,omitempty"`
+	Scopes        []string `yaml:"scopes,omitempty"`
+	Audience      string   `yaml:"audience,omitempty"`
+	RefreshBefore string   `yaml:"refresh_before,omitempty"`
+
+	// aws_sigv4
+	AccessKey    string `yaml:"access_key,omitempty"`
+	SecretKey    string `yaml:"secret_key,omitempty"`
+	SessionToken string `yaml:"session_token,omitempty"`
+	Region       string `yaml:"region,omitempty"`
+	Service      string `yaml:"service,omitempty"`
+}
+
+type authenticator struct {
+	cfg Auth
+
+	// oauth2
+	mu            sync.Mutex
+	token         string
+	refreshAt     time.Time // expiración menos el margen de refresh_before
+	refreshBefore time.Duration
+	tokenClient   *http.Client
+}
+
+// newAuthenticator: transport es el del run, así el token de oauth2 sale
+// por el mismo proxy, DNS e IPs de origen que el tráfico de carga
+func newAuthenticator(cfg *Auth, transport http.RoundTripper) (*authenticator, error) {
+	if cfg == nil {
+		return nil, nil
+	}
+	a := &authenticator{cfg: *cfg}
+	c := &a.cfg
+	for _, f := range []*string{&c.Username, &c.Password, &c.Token, &c.TokenURL, &c.ClientID,
+		&c.ClientSecret, &c.Audience, &c.AccessKey, &c.SecretKey, &c.SessionToken, &c.Region, &c.Service} {
+		*f = interpolate(*f, nil)
+	}
+
+	switch c.Type {
+	case AuthBasic:
+	case AuthBearer:
+		if c.Token == "" {
+			return nil, fmt.Errorf("auth bearer needs token")
+		}
+	case AuthOAuth2:
+		if c.TokenURL == "" || c.ClientID == "" {
+			return nil, fmt.Errorf("auth oauth2 needs token_url and client_id")
+		}
+		a.refreshBefore = 30 * time.Second
+		if c.RefreshBefore != "" {
+			d, err := time.ParseDuration(c.RefreshBefore)
+			if err != nil {
+				return nil, fmt.Errorf("invalid auth refresh_before: %v", err)
+			}
+			a.refreshBefore = d
+		}
+		a.tokenClient = &http.Client{Timeout: 15 * time.Second, Transport: transport}
+	case AuthAWSSigV4:
+		if c.AccessKey == "" {
+			c.AccessKey = os.Getenv("AWS_ACCESS_KEY_ID")
+		}
+		if c.SecretKey == "" {
+			c.SecretKey = os.Getenv("AWS_SECRET_ACCESS_KEY")
+		}
+		if c.SessionToken == "" {
+			c.SessionToken = os.Getenv("AWS_SESSION_TOKEN")
+		}
+		if c.Region == "" {
+			c.Region = os.Getenv("AWS_REGION")
+		}
+		if c.AccessKey == "" || c.SecretKey == "" || c.Region == "" || c.Service == "" {
+			return nil, fmt.Errorf("auth aws_sigv4 needs access_key, secret_key, region and service")
+		}
+	default:
+		return nil, fmt.Errorf("unknown auth type %q", c.Type)
+	}
+	return a, nil
+}
+
+// needsBody: SigV4 firma el hash del payload
+func (a *authenticator) needsBody() bool {
+	return a != nil && a.cfg.Type == AuthAWSSigV4
+}
+
+// apply agrega las credenciales a req. body es el payload ya leído
+// (solo se usa para SigV4).
+func (a *authenticator) apply(ctx context.Context, req *http.Request, body []byte) error {
+	if a == nil {
+		return nil
+	}
+	switch a.cfg.Type {
+	case AuthBasic:
+		req.SetBasicAuth(a.cfg.Username, a.cfg.Password)
+	case AuthBearer:
+		req.Header.Set("Authorization", "Bearer "+a.cfg.Token)
+	case AuthOAuth2:
+		token, err := a.oauth2Token(ctx)
+		if err != nil {
+			return err
+		}
+		req.Header.Set("Authorization", "Bearer "+token)
+	case AuthAWSSigV4:
+		a.signV4(req, body, time.Now().UTC())
+	}
+	return nil
+}
+
+// -------------------------------------------------------------
+// OAuth2 client credentials (token compartido por todos los VUs)
+// -------------------------------------------------------------
+
+func (a *authenticator) oauth2Token(ctx context.Context) (string, error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	if a.token != "" && time.Now().Before(a.refreshAt) {
+		return a.token, nil
+	}
+
+	form := url.Values{}
+	form.Set("grant_type", "client_credentials")
+	if len(a.cfg.Scopes) > 0 {
+		form.Set("scope", strings.Join(a.cfg.Scopes, " "))
+	}
+	if a.cfg.Audience != "" {
+		form.Set("audience", a.cfg.Audience)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, a.cfg.TokenURL, strings.NewReader(form.Encode()))
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Accept", "application/json")
+	req.SetBasicAuth(url.QueryEscape(a.cfg.ClientID), url.QueryEscape(a.cfg.ClientSecret))
+
+	resp, err := a.tokenClient.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("oauth2 token request failed: %w", err)
+	}
+	defer resp.Body.Close()
+	data, _ := io.ReadAll(resp.Body)
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("oauth2 token endpoint returned %d: %s", resp.StatusCode, truncate(data, 200))
+	}
+
+	var tok struct {
+		AccessToken string `json:"access_token"`
+		ExpiresIn   int    `json:"expires_in"`
+	}
+	if err := json.Unmarshal(data, &tok); err != nil || tok.AccessToken == "" {
+		return "", fmt.Errorf("oauth2 token endpoint returned no access_token")
+	}
+
+	a.token = tok.AccessToken
+	lifetime := time.Hour
+	if tok.ExpiresIn > 0 {
+		lifetime = time.Duration(tok.ExpiresIn) * time.Second
+	}
+	// Con tokens de vida corta el margen no puede comerse toda la vida del
+	// token: se renovaría en cada request
+	margin := a.refreshBefore
+	if margin > lifetime/2 {
+		margin = lifetime / 2
+	}
+	a.refreshAt = time.Now().Add(lifetime - margin)
+	return a.token, nil
+}
+
+// -------------------------------------------------------------
+// AWS Signature Version 4
+// -------------------------------------------------------------
+
+func (a *authenticator) signV4(req *http.Request, body []byte, now time.Time) {
+	amzDate := now.Format("20060102T150405Z")
+	date := now.Format("20060102")
+	payloadHash := sha256Hex(body)
+
+	req.Header.Del("Authorization")
+	req.Header.Set("X-Amz-Date", amzDate)
+	req.Header.Set("X-Amz-Content-Sha256", payloadHash)
+	if a.cfg.SessionToken != "" {
+		req.Header.Set("X-Amz-Security-Token", a.cfg.SessionToken)
+	}
+
+	// Cabeceras firmadas: host, content-type y todas las x-amz-*
+	signed := map[string]string{"host": req.Host}
+	for k, v := range req.Header {
+		lk := strings.ToLower(k)
+		if lk == "content-type" || strings.HasPrefix(lk, "x-amz-") {
+			signed[lk] = strings.TrimSpace(strings.Join(v, ","))
+		}
+	}
+	names := make([]string, 0, len(signed))
+	for k := range signed {
+		names = append(names, k)
+	}
+	sort.Strings(names)
+
+	var canonicalHeaders strings.Builder
+	for _, k := range names {
+		canonicalHeaders.WriteString(k + ":" + signed[k] + "\n")
+	}
+	signedHeaders := strings.Join(names, ";")
+
+	path := req.URL.EscapedPath()
+	if path == "" {
+		path = "/"
+	}
+
+	canonicalRequest := strings.Join([]string{
+		req.Method,
+		path,
+		canonicalQuery(req.URL.Query()),
+		canonicalHeaders.String(),
+		signedHeaders,
+		payloadHash,
+	}, "\n")
+
+	scope := fmt.Sprintf("%s/%s/%s/aws4_request", date, a.cfg.Region, a.cfg.Service)
+	stringToSign := strings.Join([]string{
+		"AWS4-HMAC-SHA256",
+		amzDate,
+		scope,
+		sha256Hex([]byte(canonicalRequest)),
+	}, "\n")
+
+	key := hmacSHA256([]byte("AWS4"+a.cfg.SecretKey), date)
+	key = hmacSHA256(key, a.cfg.Region)
+	key = hmacSHA256(key, a.cfg.Service)
+	key = hmacSHA256(key, "aws4_request")
+	signature := hex.EncodeToString(hmacSHA256(key, stringToSign))
+
+	req.Header.Set("Authorization", fmt.Sprintf("AWS4-HMAC-SHA256 Credential=%s/%s, SignedHeaders=%s, Signature=%s",
+		a.cfg.AccessKey, scope, signedHeaders, signature))
+}
+
+func canonicalQuery(q url.Values) string {
+	keys := make([]string, 0, len(q))
+	for k := range q {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	var parts []string
+	for _, k := range keys {
+		vals := append([]string(nil), q[k]...)
+		sort.Strings(vals)
+		for _, v := range vals {
+			parts = append(parts, awsEscape(k)+"="+awsEscape(v))
+		}
+	}
+	return strings.Join(parts, "&")
+}
+
+// awsEscape: RFC 3986 (espacios como %20, no como +)
+func awsEscape(s string) string {
+	return strings.ReplaceAll(url.QueryEscape(s), "+", "%20")
+}
+
+func sha256Hex(b []byte) string {
+	h := sha256.Sum256(b)
+	return hex.EncodeToString(h[:])
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	h := hmac.New(sha256.New, key)
+	h.Write([]byte(data))
+	return h.Sum(nil)
+}
@@ -0,0 +1,189 @@
+package engine
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"mime"
+	"mime/multipart"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// -------------------------------------------------------------
+// Bodies estructurados
+// -------------------------------------------------------------
+//
+// Un request usa como mucho uno de estos campos:
+//
+//	body: "raw"                         # string tal cual (formato original)
+//	json: {user: demo, tags: [a, b]}    # application/json
+//	form: {email: a@b.c, password: x}   # application/x-www-form-urlencoded
+//	multipart:                          # multipart/form-data
+//	  fields: {title: demo}
+//	  files:
+//	    - field: file
+//	      path: uploads/avatar.png
+//	body_file: uploads/payload.xml      # contenido de un archivo
+//
+// Combinar dos de ellos, o apuntar a un archivo que no se puede leer, es
+// un error al cargar el escenario.
+
+type Multipart struct {
+	Fields map[string]string `yaml:"fields,omitempty"`
+	Files  []MultipartFile   `yaml:"files,omitempty"`
+}
+
+type MultipartFile struct {
+	Field       string `yaml:"field"`
+	Path        string `yaml:"path"`
+	Filename    string `yaml:"filename,omitempty"`     // default: basename de path
+	ContentType string `yaml:"content_type,omitempty"` // default: según extensión
+}
+
+// Los archivos se leen una sola vez por proceso, no en cada iteración
+var fileCache sync.Map // path -> []byte
+
+func readFileCached(path string) ([]byte, error) {
+	if b, ok := fileCache.Load(path); ok {
+		return b.([]byte), nil
+	}
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	fileCache.Store(path, b)
+	return b, nil
+}
+
+// checkBody valida al cargar el escenario lo que buildBody resuelve en cada
+// iteración: un solo origen de body y archivos que se puedan leer
+func checkBody(reqCfg Request) error {
+	var sources []string
+	if reqCfg.Body != "" {
+		sources = append(sources, "body")
+	}
+	if reqCfg.JSON != nil {
+		sources = append(sources, "json")
+	}
+	if reqCfg.Form != nil {
+		sources = append(sources, "form")
+	}
+	if reqCfg.Multipart != nil {
+		sources = append(sources, "multipart")
+	}
+	if reqCfg.BodyFile != "" {
+		sources = append(sources, "body_file")
+	}
+	if len(sources) > 1 {
+		return fmt.Errorf("only one of body, json, form, multipart or body_file can be set (got %s)", strings.Join(sources, ", "))
+	}
+
+	if reqCfg.BodyFile != "" {
+		if _, err := readFileCached(reqCfg.BodyFile); err != nil {
+			return fmt.Errorf("cannot read body_file: %v", err)
+		}
+	}
+	if reqCfg.Multipart != nil {
+		for _, f := range reqCfg.Multipart.Files {
+			if _, err := readFileCached(f.Path); err != nil {
+				return fmt.Errorf("cannot read multipart file: %v", err)
+			}
+		}
+	}
+	return nil
+}
+
+// buildBody devuelve el body del request y el Content-Type que le corresponde.
+// forceType indica que el Content-Type debe pisar al grabado (boundary de multipart).
+func buildBody(reqCfg Request) (body io.Reader, contentType string, forceType bool, err error) {
+	switch {
+	case reqCfg.JSON != nil:
+		b, err := json.Marshal(reqCfg.JSON)
+		if err != nil {
+			return nil, "", false, fmt.Errorf("cannot encode json body: %v", err)
+		}
+		return bytes.NewReader(b), "application/json", false, nil
+
+	case reqCfg.Form != nil:
+		values := url.Values{}
+		for k, v := range reqCfg.Form {
+			values.Set(k, v)
+		}
+		return bytes.NewBufferString(values.Encode()), "application/x-www-form-urlencoded", false, nil
+
+	case reqCfg.Multipart != nil:
+		b, ct, err := encodeMultipart(reqCfg.Multipart)
+		if err != nil {
+			return nil, "", false, err
+		}
+		return bytes.NewReader(b), ct, true, nil
+
+	case reqCfg.BodyFile != "":
+		b, err := readFileCached(reqCfg.BodyFile)
+		if err != nil {
+			return nil, "", false, fmt.Errorf("cannot read body_file: %v", err)
+		}
+		return bytes.NewReader(b), mime.TypeByExtension(filepath.Ext(reqCfg.BodyFile)), false, nil
+
+	case reqCfg.Body != "":
+		return bytes.NewBufferString(reqCfg.Body), "", false, nil
+	}
+	return nil, "", false, nil
+}
+
+func encodeMultipart(m *Multipart) ([]byte, string, error) {
+	var buf bytes.Buffer
+	w := multipart.NewWriter(&buf)
+
+	// orden estable de los campos
+	names := make([]string, 0, len(m.Fields))
+	for k := range m.Fields {
+		names = append(names, k)
+	}
+	sort.Strings(names)
+	for _, k := range names {
+		if err := w.WriteField(k, m.Fields[k]); err != nil {
+			return nil, "", err
+		}
+	}
+
+	for _, f := range m.Files {
+		data, err := readFileCached(f.Path)
+		if err != nil {
+			return nil, "", fmt.Errorf("cannot read multipart file: %v", err)
+		}
+		filename := f.Filename
+		if filename == "" {
+			filename = filepath.Base(f.Path)
+		}
+		ct := f.ContentType
+		if ct == "" {
+			ct = mime.TypeByExtension(filepath.Ext(filename))
+		}
+		if ct == "" {
+			ct = "application/octet-stream"
+		}
+
+		h := make(map[string][]string)
+		h["Content-Disposition"] = []string{fmt.Sprintf(`form-data; name=%q; filename=%q`, f.Field, filename)}
+		h["Content-Type"] = []string{ct}
+		part, err := w.CreatePart(h)
+		if err != nil {
+			return nil, "", err
+		}
+		if _, err := part.Write(data); err != nil {
+			return nil, "", err
+		}
+	}
+
+	if err := w.Close(); err != nil {
+		return nil, "", err
+	}
+	return buf.Bytes(), w.FormDataContentType(), nil
+}
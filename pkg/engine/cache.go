@@ -0,0 +1,180 @@
+package engine
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// -------------------------------------------------------------
+// Caché HTTP por usuario virtual
+// -------------------------------------------------------------
+//
+//	cache:
+//	  enabled: true
+//	  max_entries: 500
+//
+// Emula la caché de un navegador: cada VU guarda las respuestas GET
+// cacheables (Cache-Control max-age / Expires, ETag, Last-Modified). Una
+// entrada fresca no sale a la red (cache hit); una vencida se revalida con
+// If-None-Match / If-Modified-Since y un 304 la renueva. Las cabeceras
+// condicionales grabadas en el YAML se descartan: las gestiona la caché.
+
+type Cache struct {
+	Enabled    bool `yaml:"enabled"`
+	MaxEntries int  `yaml:"max_entries,omitempty"`
+}
+
+type cacheEntry struct {
+	etag         string
+	lastModified string
+	expires      time.Time // fresco hasta este instante
+	noCache      bool      // siempre revalidar
+}
+
+type httpCache struct {
+	mu      sync.Mutex
+	max     int
+	entries map[string]*cacheEntry
+}
+
+func newHTTPCache(cfg *Cache) *httpCache {
+	if cfg == nil || !cfg.Enabled {
+		return nil
+	}
+	max := cfg.MaxEntries
+	if max <= 0 {
+		max = 500
+	}
+	return &httpCache{max: max, entries: make(map[string]*cacheEntry)}
+}
+
+// fresh indica si url puede servirse desde la caché sin ir a la red
+func (c *httpCache) fresh(method, url string) bool {
+	if c == nil || method != http.MethodGet {
+		return false
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	e, ok := c.entries[url]
+	return ok && !e.noCache && time.Now().Before(e.expires)
+}
+
+// conditional prepara req: quita los validadores grabados y agrega los
+// de la entrada en caché, si existe
+func (c *httpCache) conditional(req *http.Request, url string) {
+	if c == nil || req.Method != http.MethodGet {
+		return
+	}
+	req.Header.Del("If-None-Match")
+	req.Header.Del("If-Modified-Since")
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	e, ok := c.entries[url]
+	if !ok {
+		return
+	}
+	if e.etag != "" {
+		req.Header.Set("If-None-Match", e.etag)
+	}
+	if e.lastModified != "" {
+		req.Header.Set("If-Modified-Since", e.lastModified)
+	}
+}
+
+// store actualiza la caché con la respuesta (200 nuevo o 304 que renueva)
+func (c *httpCache) store(req *http.Request, url string, resp *http.Response) {
+	if c == nil || req.Method != http.MethodGet {
+		return
+	}
+	if resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusNotModified {
+		return
+	}
+
+	cc := parseCacheControl(resp.Header.Get("Cache-Control"))
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if _, noStore := cc["no-store"]; noStore {
+		delete(c.entries, url)
+		return
+	}
+
+	e, ok := c.entries[url]
+	if resp.StatusCode == http.StatusNotModified && !ok {
+		return
+	}
+	if !ok {
+		e = &cacheEntry{}
+	}
+	if v := resp.Header.Get("ETag"); v != "" {
+		e.etag = v
+	}
+	if v := resp.Header.Get("Last-Modified"); v != "" {
+		e.lastModified = v
+	}
+	_, e.noCache = cc["no-cache"]
+	e.expires = freshUntil(resp.Header, cc)
+
+	if e.etag == "" && e.lastModified == "" && !time.Now().Before(e.expires) {
+		delete(c.entries, url) // ni fresca ni revalidable
+		return
+	}
+	if !ok {
+		if len(c.entries) >= c.max {
+			c.evict()
+		}
+		c.entries[url] = e
+	}
+}
+
+// evict descarta la entrada que vence primero
+func (c *httpCache) evict() {
+	var oldest string
+	var at time.Time
+	for k, e := range c.entries {
+		if oldest == "" || e.expires.Before(at) {
+			oldest, at = k, e.expires
+		}
+	}
+	delete(c.entries, oldest)
+}
+
+func freshUntil(h http.Header, cc map[string]string) time.Time {
+	now := time.Now()
+	if v, ok := cc["max-age"]; ok {
+		secs, err := strconv.Atoi(v)
+		if err != nil {
+			return now
+		}
+		if age, err := strconv.Atoi(h.Get("Age")); err == nil {
+			secs -= age
+		}
+		return now.Add(time.Duration(secs) * time.Second)
+	}
+	if v := h.Get("Expires"); v != "" {
+		if t, err := http.ParseTime(v); err == nil {
+			return t
+		}
+	}
+	return now
+}
+
+func parseCacheControl(v string) map[string]string {
+	out := make(map[string]string)
+	for _, part := range strings.Split(v, ",") {
+		part = strings.TrimSpace(strings.ToLower(part))
+		if part == "" {
+			continue
+		}
+		if k, val, ok := strings.Cut(part, "="); ok {
+			out[k] = strings.Trim(val, `"`)
+		} else {
+			out[part] = ""
+		}
+	}
+	return out
+}
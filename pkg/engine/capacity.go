@@ -0,0 +1,132 @@
+package engine
+
+import (
+	"fmt"
+	"time"
+)
+
+// -------------------------------------------------------------
+// Búsqueda de capacidad (pulse capacity)
+// -------------------------------------------------------------
+//
+//	pulse capacity scenario.yaml --start 10 --step 10 --max 300 \
+//	    --step-duration 30s --p95 500ms --error-rate 1
+//
+// Corre el escenario en escalones de concurrencia creciente (sin ramp-up,
+// cada uno con la duración indicada) y se detiene en el primero que rompe
+// el SLO. El resultado es el último escalón sostenible y las métricas de
+// cada uno. Un rate_limit del escenario sigue aplicando y acota la carga.
+
+type CapacityOptions struct {
+	Options
+
+	Start        int           // VUs del primer escalón
+	Step         int           // VUs que suma cada escalón
+	Max          int           // tope de VUs
+	StepDuration time.Duration // duración de cada escalón
+	MaxP95       time.Duration // SLO de p95; 0 = no se evalúa
+	MaxErrorRate float64       // SLO de error en %; < 0 = no se evalúa
+}
+
+type CapacityStep struct {
+	Concurrency int
+	Summary     Summary
+	Passed      bool
+	Reason      string // qué parte del SLO se rompió
+}
+
+type CapacityReport struct {
+	Steps       []CapacityStep
+	Sustainable int // VUs del último escalón que cumplió el SLO (0 = ninguno)
+}
+
+// Capacity busca la carga máxima que cumple el SLO
+func Capacity(path string, opts CapacityOptions) (*CapacityReport, error) {
+	if opts.Start <= 0 || opts.Step <= 0 || opts.Max < opts.Start {
+		return nil, fmt.Errorf("capacity needs start > 0, step > 0 and max >= start")
+	}
+	if opts.StepDuration <= 0 {
+		return nil, fmt.Errorf("capacity needs a step duration")
+	}
+	if opts.MaxP95 <= 0 && opts.MaxErrorRate < 0 {
+		return nil, fmt.Errorf("capacity needs an SLO (p95 and/or error rate)")
+	}
+
+	scenario, err := loadScenario(path)
+	if err != nil {
+		return nil, err
+	}
+	fmt.Printf("🔎 Capacity search: %s (%d → %d VUs, +%d every %s)\n",
+		scenario.Name, opts.Start, opts.Max, opts.Step, opts.StepDuration)
+
+	report := &CapacityReport{}
+	runOpts := opts.Options
+	runOpts.quiet = true
+	for conc := opts.Start; conc <= opts.Max; conc += opts.Step {
+		sum, err := runLevel(scenario, runOpts, conc, opts.StepDuration)
+		if err != nil {
+			return nil, err
+		}
+
+		step := CapacityStep{Concurrency: conc, Summary: *sum, Passed: true}
+		switch {
+		case sum.Aborted != "":
+			step.Passed, step.Reason = false, "aborted: "+sum.Aborted
+		case sum.Requests == 0:
+			step.Passed, step.Reason = false, "no requests completed"
+		case opts.MaxP95 > 0 && sum.P95Ms > ms(opts.MaxP95):
+			step.Passed, step.Reason = false, fmt.Sprintf("p95 %.2fms > %s", sum.P95Ms, opts.MaxP95)
+		case opts.MaxErrorRate >= 0 && sum.ErrorRate > opts.MaxErrorRate:
+			step.Passed, step.Reason = false, fmt.Sprintf("error rate %.2f%% > %.2f%%", sum.ErrorRate, opts.MaxErrorRate)
+		}
+		report.Steps = append(report.Steps, step)
+
+		mark := "✅"
+		if !step.Passed {
+			mark = "❌ " + step.Reason
+		}
+		fmt.Printf("  %4d VUs: %8.1f req/s | p95 %8.2fms | errors %6.2f%% %s\n",
+			conc, sum.RPS, sum.P95Ms, sum.ErrorRate, mark)
+
+		if !step.Passed {
+			break
+		}
+		report.Sustainable = conc
+	}
+
+	printCapacityReport(report)
+	return report, nil
+}
+
+// runLevel corre el escenario con concurrency fija durante d
+func runLevel(scenario Scenario, opts Options, concurrency int, d time.Duration) (*Summary, error) {
+	scenario.Profile.Concurrency = concurrency
+	scenario.Profile.Duration = d.String()
+	scenario.Profile.RampUp = ""
+	return runScenario(scenario, opts, nil)
+}
+
+func printCapacityReport(report *CapacityReport) {
+	fmt.Println("\n--- CAPACITY ---")
+	fmt.Printf("%-10s %-10s %-10s %-10s %-10s %s\n", "VUs", "Req/s", "Avg(ms)", "P95(ms)", "Err(%)", "SLO")
+	for _, st := range report.Steps {
+		slo := "ok"
+		if !st.Passed {
+			slo = st.Reason
+		}
+		s := st.Summary
+		fmt.Printf("%-10d %-10.1f %-10.2f %-10.2f %-10.2f %s\n", st.Concurrency, s.RPS, s.AvgMs, s.P95Ms, s.ErrorRate, slo)
+	}
+
+	fmt.Println()
+	for _, st := range report.Steps {
+		if st.Concurrency == report.Sustainable {
+			fmt.Printf("Max sustainable load: %d VUs (%.1f req/s, p95 %.2fms)\n", st.Concurrency, st.Summary.RPS, st.Summary.P95Ms)
+			if report.Steps[len(report.Steps)-1].Passed {
+				fmt.Println("SLO never broke: raise --max to keep searching")
+			}
+			return
+		}
+	}
+	fmt.Println("Max sustainable load: none (SLO broken at the first step)")
+}
@@ -0,0 +1,176 @@
+package engine
+
+import (
+	"fmt"
+	"math/rand"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+// -------------------------------------------------------------
+// Captura de request/response a disco (para depurar fallos)
+// -------------------------------------------------------------
+//
+//	capture:
+//	  dir: results            # default: results
+//	  success_sample: 0.01    # fracción de requests OK a guardar (default 0)
+//	  max_body: 8192          # bytes por body antes de truncar
+//	  max_files: 1000         # tope de archivos por run
+//
+// Los fallos se guardan siempre que el bloque esté presente. Cada muestra
+// va a un archivo dentro de <dir>/run_<timestamp>_capture/. Los headers con
+// credenciales (ver redactedHeaders) se guardan como [REDACTED].
+
+type Capture struct {
+	Dir           string  `yaml:"dir,omitempty"`
+	SuccessSample float64 `yaml:"success_sample,omitempty"`
+	MaxBody       int     `yaml:"max_body,omitempty"`
+	MaxFiles      int     `yaml:"max_files,omitempty"`
+}
+
+// exchange: request/response de un intento, tal como se enviaron
+type exchange struct {
+	limit      int // bytes de body de respuesta a conservar
+	method     string
+	url        string
+	reqHeader  http.Header
+	reqBody    []byte
+	status     string
+	respHeader http.Header
+	respBody   []byte
+	truncated  bool
+}
+
+type capturer struct {
+	dir      string
+	sample   float64
+	maxBody  int
+	maxFiles int64
+	seq      int64
+}
+
+func newCapturer(cfg *Capture, start time.Time) (*capturer, error) {
+	if cfg == nil {
+		return nil, nil
+	}
+	base := cfg.Dir
+	if base == "" {
+		base = "results"
+	}
+	c := &capturer{
+		dir:      filepath.Join(base, fmt.Sprintf("run_%s_capture", start.Format("2006-01-02_150405"))),
+		sample:   cfg.SuccessSample,
+		maxBody:  cfg.MaxBody,
+		maxFiles: int64(cfg.MaxFiles),
+	}
+	if c.maxBody <= 0 {
+		c.maxBody = 8192
+	}
+	if c.maxFiles <= 0 {
+		c.maxFiles = 1000
+	}
+	if err := os.MkdirAll(c.dir, 0755); err != nil {
+		return nil, fmt.Errorf("cannot create capture dir: %v", err)
+	}
+	return c, nil
+}
+
+// wants decide si una muestra se guarda: todos los fallos y un % de los OK
+func (c *capturer) wants(failed bool) bool {
+	if c == nil {
+		return false
+	}
+	if !failed && (c.sample <= 0 || rand.Float64() >= c.sample) {
+		return false
+	}
+	return atomic.LoadInt64(&c.seq) < c.maxFiles
+}
+
+func (c *capturer) save(r result, ex *exchange) {
+	seq := atomic.AddInt64(&c.seq, 1)
+	if seq > c.maxFiles {
+		return
+	}
+
+	kind := "ok"
+	if r.err != nil {
+		kind = "fail"
+	}
+	filename := fmt.Sprintf("%06d_%s_%s.txt", seq, kind, sanitizeName(r.name))
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "# %s | status %d | %.2fms | retries %d\n", r.name, r.status, ms(r.latency), r.retries)
+	if r.err != nil {
+		fmt.Fprintf(&b, "# error: %v\n", r.err)
+	}
+
+	fmt.Fprintf(&b, "\n> %s %s\n", ex.method, ex.url)
+	writeHeaders(&b, "> ", ex.reqHeader)
+	if len(ex.reqBody) > 0 {
+		b.WriteString("\n")
+		b.Write(truncate(ex.reqBody, c.maxBody))
+		b.WriteString("\n")
+	}
+
+	if ex.status != "" {
+		fmt.Fprintf(&b, "\n< %s\n", ex.status)
+		writeHeaders(&b, "< ", ex.respHeader)
+		if len(ex.respBody) > 0 {
+			b.WriteString("\n")
+			b.Write(ex.respBody)
+			if ex.truncated {
+				fmt.Fprintf(&b, "\n... (truncated at %d bytes)", ex.limit)
+			}
+			b.WriteString("\n")
+		}
+	}
+
+	_ = os.WriteFile(filepath.Join(c.dir, filename), []byte(b.String()), 0644)
+}
+
+// redactedHeaders: headers con credenciales, incluidas las que genera auth
+var redactedHeaders = map[string]bool{
+	"Authorization":        true,
+	"Proxy-Authorization":  true,
+	"Cookie":               true,
+	"Set-Cookie":           true,
+	"X-Amz-Security-Token": true,
+}
+
+func writeHeaders(b *strings.Builder, prefix string, h http.Header) {
+	keys := make([]string, 0, len(h))
+	for k := range h {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		val := strings.Join(h[k], ", ")
+		if redactedHeaders[http.CanonicalHeaderKey(k)] {
+			val = "[REDACTED]"
+		}
+		fmt.Fprintf(b, "%s%s: %s\n", prefix, k, val)
+	}
+}
+
+func truncate(b []byte, max int) []byte {
+	if len(b) > max {
+		return b[:max]
+	}
+	return b
+}
+
+func sanitizeName(s string) string {
+	s = strings.ReplaceAll(s, "/", "_")
+	s = strings.ReplaceAll(s, " ", "_")
+	s = strings.ReplaceAll(s, "?", "_")
+	s = strings.ReplaceAll(s, ":", "_")
+	if len(s) > 80 {
+		s = s[:80]
+	}
+	return s
+}
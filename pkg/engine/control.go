@@ -0,0 +1,404 @@
+package engine
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// -------------------------------------------------------------
+// Control en vivo de un run
+// -------------------------------------------------------------
+//
+//	ctl := engine.NewControl()
+//	go engine.RunWithOptions(path, engine.Options{Control: ctl}, events)
+//	ctl.SetVUs(50)      // sube o baja los VUs activos
+//	ctl.SetRate(200)    // rps total del escenario (0 = sin límite)
+//	ctl.Pause(); ctl.Resume()
+//
+// Por HTTP, ctl.Handler() expone GET (estado) y POST con {"vus": 50},
+// {"rps": 200} o {"action": "pause" | "resume"}. Cada cambio queda como
+// anotación: un Event SYSTEM "ANNOTATION", una línea en el resumen y una
+// entrada en Summary.Annotations.
+//
+// Los VUs que se bajan terminan su iteración en curso. Pausar frena a los
+// VUs antes del siguiente request; el tiempo en pausa cuenta para duration
+// pero no como demora en las latencias corregidas.
+
+type Control struct {
+	mu  sync.Mutex
+	run *liveRun // nil = sin run en curso
+}
+
+type ControlStatus struct {
+	Running  bool    `json:"running"`
+	VUs      int     `json:"vus"`
+	RPS      float64 `json:"rps"` // 0 = sin límite a nivel escenario
+	Paused   bool    `json:"paused"`
+	ElapsedS float64 `json:"elapsed_s"`
+}
+
+func NewControl() *Control {
+	return &Control{}
+}
+
+func (c *Control) attach(lr *liveRun) {
+	if c == nil {
+		return
+	}
+	c.mu.Lock()
+	c.run = lr
+	c.mu.Unlock()
+}
+
+func (c *Control) detach(lr *liveRun) {
+	if c == nil {
+		return
+	}
+	c.mu.Lock()
+	if c.run == lr {
+		c.run = nil
+	}
+	c.mu.Unlock()
+}
+
+func (c *Control) current() (*liveRun, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.run == nil {
+		return nil, fmt.Errorf("no run in progress")
+	}
+	return c.run, nil
+}
+
+// SetVUs fija la cantidad de VUs activos
+func (c *Control) SetVUs(n int) error {
+	if n < 0 {
+		return fmt.Errorf("invalid vus %d", n)
+	}
+	lr, err := c.current()
+	if err != nil {
+		return err
+	}
+	from, err := lr.pool.resize(n)
+	if err != nil {
+		return err
+	}
+	lr.annotate(fmt.Sprintf("vus %d -> %d", from, n))
+	return nil
+}
+
+// SetRate fija el rps total del escenario; 0 lo deja sin límite
+func (c *Control) SetRate(rps float64) error {
+	if rps < 0 {
+		return fmt.Errorf("invalid rps %g", rps)
+	}
+	lr, err := c.current()
+	if err != nil {
+		return err
+	}
+	from := lr.rn.setRate(rps)
+	lr.annotate(fmt.Sprintf("rps %s -> %s", rateLabel(from), rateLabel(rps)))
+	return nil
+}
+
+func (c *Control) Pause() error {
+	lr, err := c.current()
+	if err != nil {
+		return err
+	}
+	if !lr.rn.gate.pause() {
+		return fmt.Errorf("run already paused")
+	}
+	lr.annotate("paused")
+	return nil
+}
+
+func (c *Control) Resume() error {
+	lr, err := c.current()
+	if err != nil {
+		return err
+	}
+	if !lr.rn.gate.resume() {
+		return fmt.Errorf("run is not paused")
+	}
+	lr.rn.resetSchedules()
+	lr.annotate("resumed")
+	return nil
+}
+
+func (c *Control) Status() ControlStatus {
+	lr, err := c.current()
+	if err != nil {
+		return ControlStatus{}
+	}
+	return ControlStatus{
+		Running:  true,
+		VUs:      lr.pool.size(),
+		RPS:      lr.rn.currentRate(),
+		Paused:   lr.rn.gate.paused(),
+		ElapsedS: time.Since(lr.start).Seconds(),
+	}
+}
+
+// controlRequest: cuerpo del POST; se aplica lo que venga informado
+type controlRequest struct {
+	VUs    *int     `json:"vus"`
+	RPS    *float64 `json:"rps"`
+	Action string   `json:"action"`
+}
+
+// Handler expone el control por HTTP (GET estado, POST cambios)
+func (c *Control) Handler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		switch r.Method {
+		case http.MethodGet:
+		case http.MethodPost:
+			var req controlRequest
+			if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+				http.Error(w, `{"error":"invalid body"}`, http.StatusBadRequest)
+				return
+			}
+			if err := c.apply(req); err != nil {
+				w.WriteHeader(http.StatusConflict)
+				json.NewEncoder(w).Encode(map[string]string{"error": err.Error()})
+				return
+			}
+		default:
+			http.Error(w, `{"error":"method not allowed"}`, http.StatusMethodNotAllowed)
+			return
+		}
+		json.NewEncoder(w).Encode(c.Status())
+	})
+}
+
+func (c *Control) apply(req controlRequest) error {
+	if req.VUs != nil {
+		if err := c.SetVUs(*req.VUs); err != nil {
+			return err
+		}
+	}
+	if req.RPS != nil {
+		if err := c.SetRate(*req.RPS); err != nil {
+			return err
+		}
+	}
+	switch req.Action {
+	case "":
+		return nil
+	case "pause":
+		return c.Pause()
+	case "resume":
+		return c.Resume()
+	}
+	return fmt.Errorf("unknown action %q", req.Action)
+}
+
+func rateLabel(rps float64) string {
+	if rps <= 0 {
+		return "unlimited"
+	}
+	return fmt.Sprintf("%g", rps)
+}
+
+// -------------------------------------------------------------
+// Estado vivo del run: VUs, pausa y anotaciones
+// -------------------------------------------------------------
+
+type annotation struct {
+	at   time.Duration // desde el inicio del run
+	text string
+}
+
+type liveRun struct {
+	rn    *runner
+	pool  *vuPool
+	sink  *eventSink
+	start time.Time
+
+	mu          sync.Mutex
+	annotations []annotation
+}
+
+func (lr *liveRun) annotate(text string) {
+	lr.mu.Lock()
+	lr.annotations = append(lr.annotations, annotation{at: time.Since(lr.start), text: text})
+	lr.mu.Unlock()
+	lr.sink.emit(Event{
+		Timestamp:   time.Now(),
+		Name:        "ANNOTATION",
+		Method:      "SYSTEM",
+		Path:        text,
+		Concurrency: lr.pool.size(),
+	})
+}
+
+// vuPool: VUs vivos del run. Tras closed (fin de duration) no se suman más.
+type vuPool struct {
+	mu     sync.Mutex
+	wg     sync.WaitGroup
+	closed bool
+	active []*vu
+	next   int                                       // índice del próximo worker
+	launch func(idx int, v *vu, delay time.Duration) // arranca la goroutine del VU
+	newVU  func() *vu
+}
+
+// add suma n VUs; el i-ésimo arranca tras i*step (ramp-up)
+func (p *vuPool) add(n int, step time.Duration) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.closed {
+		return fmt.Errorf("run is finishing")
+	}
+	for i := 0; i < n; i++ {
+		v := p.newVU()
+		p.active = append(p.active, v)
+		p.wg.Add(1)
+		go p.launch(p.next, v, step*time.Duration(i))
+		p.next++
+	}
+	return nil
+}
+
+// resize sube o baja a n VUs; devuelve cuántos había
+func (p *vuPool) resize(n int) (int, error) {
+	p.mu.Lock()
+	from := len(p.active)
+	if n <= from {
+		// Baja los más nuevos: terminan la iteración en curso
+		for _, v := range p.active[n:] {
+			v.retired.Store(true)
+		}
+		p.active = p.active[:n]
+		p.mu.Unlock()
+		return from, nil
+	}
+	p.mu.Unlock()
+	return from, p.add(n-from, 0)
+}
+
+// remove saca del pool a un VU que terminó por su cuenta (on_error: stop_vu)
+func (p *vuPool) remove(v *vu) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	for i, cur := range p.active {
+		if cur == v {
+			p.active = append(p.active[:i], p.active[i+1:]...)
+			return
+		}
+	}
+}
+
+func (p *vuPool) size() int {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return len(p.active)
+}
+
+// close impide sumar VUs; el WaitGroup queda solo con los VUs vivos
+func (p *vuPool) close() {
+	p.mu.Lock()
+	if !p.closed {
+		p.closed = true
+		p.wg.Done()
+	}
+	p.mu.Unlock()
+}
+
+// -------------------------------------------------------------
+// Pausa
+// -------------------------------------------------------------
+
+type pauseGate struct {
+	mu      sync.Mutex
+	resumed chan struct{} // nil = corriendo
+}
+
+// wait bloquea mientras el run esté en pausa; devuelve cuánto esperó
+func (g *pauseGate) wait(ctx context.Context) (time.Duration, error) {
+	g.mu.Lock()
+	ch := g.resumed
+	g.mu.Unlock()
+	if ch == nil {
+		return 0, nil
+	}
+	t0 := time.Now()
+	select {
+	case <-ch:
+		return time.Since(t0), nil
+	case <-ctx.Done():
+		return time.Since(t0), ctx.Err()
+	}
+}
+
+func (g *pauseGate) pause() bool {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if g.resumed != nil {
+		return false
+	}
+	g.resumed = make(chan struct{})
+	return true
+}
+
+func (g *pauseGate) resume() bool {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if g.resumed == nil {
+		return false
+	}
+	close(g.resumed)
+	g.resumed = nil
+	return true
+}
+
+func (g *pauseGate) paused() bool {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	return g.resumed != nil
+}
+
+// -------------------------------------------------------------
+// Rate en vivo
+// -------------------------------------------------------------
+
+// setRate cambia el rps del escenario (pisa el schedule); devuelve el anterior
+func (rn *runner) setRate(rps float64) float64 {
+	now := time.Now()
+	l := rn.scenarioLimit.Load()
+	from := 0.0
+	if l != nil {
+		from = l.currentRate(now)
+	}
+	switch {
+	case rps <= 0:
+		rn.scenarioLimit.Store(nil)
+	case l == nil:
+		l, _ = newLimiter(&RateLimit{RPS: rps}, now)
+		rn.scenarioLimit.Store(l)
+	default:
+		l.setRate(rps, now)
+	}
+	return from
+}
+
+func (rn *runner) currentRate() float64 {
+	if l := rn.scenarioLimit.Load(); l != nil {
+		return l.currentRate(time.Now())
+	}
+	return 0
+}
+
+// resetSchedules descarta los slots que vencieron durante una pausa
+func (rn *runner) resetSchedules() {
+	now := time.Now()
+	rn.scenarioLimit.Load().resetSchedule(now)
+	for _, l := range rn.requestLimits {
+		l.resetSchedule(now)
+	}
+}
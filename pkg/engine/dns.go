@@ -0,0 +1,138 @@
+package engine
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// -------------------------------------------------------------
+// Resolución DNS del run
+// -------------------------------------------------------------
+//
+//	dns:
+//	  hosts:                      # overrides, como /etc/hosts
+//	    api.example.com: [10.0.0.11, 10.0.0.12]
+//	  strategy: round_robin       # first (default) | round_robin
+//	  mode: cached                # cached (default) | per_connection
+//	  ttl: 60s                    # vigencia de la caché en modo cached
+//
+// Con round_robin cada conexión nueva empieza por la siguiente dirección
+// de la lista, lo que permite repartir la carga entre backends detrás de
+// un balanceador. Si una dirección no responde se prueba la siguiente.
+// Sin bloque dns se usa el resolver del sistema en cada conexión.
+
+type DNS struct {
+	Hosts    map[string][]string `yaml:"hosts,omitempty"`
+	Strategy string              `yaml:"strategy,omitempty"`
+	Mode     string              `yaml:"mode,omitempty"`
+	TTL      string              `yaml:"ttl,omitempty"`
+}
+
+type dnsEntry struct {
+	addrs   []string
+	expires time.Time
+}
+
+type dnsResolver struct {
+	hosts      map[string][]string
+	roundRobin bool
+	ttl        time.Duration // 0 = resolver en cada conexión
+
+	mu    sync.Mutex
+	cache map[string]dnsEntry
+	next  sync.Map // host -> *uint64, contador del round-robin
+}
+
+func newDNSResolver(cfg *DNS) (*dnsResolver, error) {
+	if cfg == nil {
+		return nil, nil
+	}
+	r := &dnsResolver{hosts: make(map[string][]string), cache: make(map[string]dnsEntry)}
+
+	for host, ips := range cfg.Hosts {
+		if len(ips) == 0 {
+			return nil, fmt.Errorf("dns host %q has no addresses", host)
+		}
+		for _, ip := range ips {
+			if net.ParseIP(ip) == nil {
+				return nil, fmt.Errorf("dns host %q: invalid address %q", host, ip)
+			}
+		}
+		r.hosts[host] = ips
+	}
+
+	switch cfg.Strategy {
+	case "", "first":
+	case "round_robin":
+		r.roundRobin = true
+	default:
+		return nil, fmt.Errorf("unknown dns strategy %q", cfg.Strategy)
+	}
+
+	switch cfg.Mode {
+	case "", "cached":
+		r.ttl = 60 * time.Second
+		if cfg.TTL != "" {
+			ttl, err := time.ParseDuration(cfg.TTL)
+			if err != nil || ttl <= 0 {
+				return nil, fmt.Errorf("invalid dns ttl %q", cfg.TTL)
+			}
+			r.ttl = ttl
+		}
+	case "per_connection":
+	default:
+		return nil, fmt.Errorf("unknown dns mode %q", cfg.Mode)
+	}
+	return r, nil
+}
+
+// lookup devuelve las direcciones de host, ya rotadas según la estrategia
+func (r *dnsResolver) lookup(ctx context.Context, host string) ([]string, error) {
+	addrs, ok := r.hosts[host]
+	if !ok {
+		var err error
+		if addrs, err = r.resolve(ctx, host); err != nil {
+			return nil, err
+		}
+	}
+	if !r.roundRobin || len(addrs) < 2 {
+		return addrs, nil
+	}
+
+	c, _ := r.next.LoadOrStore(host, new(uint64))
+	start := int((atomic.AddUint64(c.(*uint64), 1) - 1) % uint64(len(addrs)))
+	out := make([]string, 0, len(addrs))
+	out = append(out, addrs[start:]...)
+	return append(out, addrs[:start]...), nil
+}
+
+func (r *dnsResolver) resolve(ctx context.Context, host string) ([]string, error) {
+	if r.ttl > 0 {
+		r.mu.Lock()
+		e, ok := r.cache[host]
+		r.mu.Unlock()
+		if ok && time.Now().Before(e.expires) {
+			return e.addrs, nil
+		}
+	}
+
+	ips, err := net.DefaultResolver.LookupIPAddr(ctx, host)
+	if err != nil {
+		return nil, err
+	}
+	addrs := make([]string, len(ips))
+	for i, ip := range ips {
+		addrs[i] = ip.IP.String()
+	}
+
+	if r.ttl > 0 {
+		r.mu.Lock()
+		r.cache[host] = dnsEntry{addrs: addrs, expires: time.Now().Add(r.ttl)}
+		r.mu.Unlock()
+	}
+	return addrs, nil
+}
@@ -1,13 +1,19 @@
 package engine
 
 import (
+	"bufio"
 	"bytes"
+	"compress/flate"
+	"compress/gzip"
+	"compress/zlib"
+	"context"
 	"fmt"
 	"io"
 	"net/http"
 	"os"
+	"regexp"
 	"sort"
-	"sync"
+	"strings"
 	"sync/atomic"
 	"time"
 
@@ -18,14 +24,40 @@ import (
 // Estructuras base del escenario (nuevo formato con scenarios)
 // -------------------------------------------------------------
 
+// Tipos de step (Request.Type); vacío equivale a http
+const (
+	StepHTTP      = "http"
+	StepWebSocket = "websocket"
+	StepSSE       = "sse"
+	StepGraphQL   = "graphql"
+	StepTCP       = "tcp"
+	StepUDP       = "udp"
+)
+
 type Request struct {
-	Name     string            `yaml:"name"`
-	Method   string            `yaml:"method"`
-	Protocol string            `yaml:"protocol"`
-	Host     string            `yaml:"host"`
-	Path     string            `yaml:"path"`
-	Headers  map[string]string `yaml:"headers"`
-	Body     string            `yaml:"body,omitempty"`
+	Name      string            `yaml:"name"`
+	Type      string            `yaml:"type,omitempty"`
+	Method    string            `yaml:"method"`
+	Protocol  string            `yaml:"protocol"`
+	Host      string            `yaml:"host"`
+	Path      string            `yaml:"path"`
+	Headers   map[string]string `yaml:"headers"`
+	Body      string            `yaml:"body,omitempty"`
+	JSON      map[string]any    `yaml:"json,omitempty"`
+	Form      map[string]string `yaml:"form,omitempty"`
+	Multipart *Multipart        `yaml:"multipart,omitempty"`
+	BodyFile  string            `yaml:"body_file,omitempty"`
+	Retry     *Retry            `yaml:"retry,omitempty"`
+	RateLimit *RateLimit        `yaml:"rate_limit,omitempty"`
+	WebSocket *WebSocketStep    `yaml:"websocket,omitempty"`
+	SSE       *SSEStep          `yaml:"sse,omitempty"`
+	GraphQL   *GraphQLStep      `yaml:"graphql,omitempty"`
+	Socket    *SocketStep       `yaml:"socket,omitempty"` // tcp / udp
+	Resources *Resources        `yaml:"resources,omitempty"`
+	ThinkTime *ThinkTime        `yaml:"think_time,omitempty"` // pausa tras el request
+	OnError   string            `yaml:"on_error,omitempty"`   // ver abort.go
+
+	skipAuth bool // recursos de otros dominios: sin credenciales
 }
 
 type Profile struct {
@@ -35,12 +67,29 @@ type Profile struct {
 	RampDown      string `yaml:"ramp_down"`
 	Iterations    int    `yaml:"iterations"`
 	StartupDelay  string `yaml:"startup_delay"`
+	GracefulStop  string `yaml:"graceful_stop,omitempty"`
+	Pacing        string `yaml:"pacing,omitempty"` // duración mínima de cada iteración
+	WarmUp        string `yaml:"warm_up,omitempty"` // carga previa a duration, fuera de las métricas
 }
 
 type Scenario struct {
-	Name     string    `yaml:"name"`
-	Profile  Profile   `yaml:"profile"`
-	Requests []Request `yaml:"requests"`
+	Name      string            `yaml:"name"`
+	Profile   Profile           `yaml:"profile"`
+	Variables map[string]string `yaml:"variables,omitempty"`  // ${name} en graphql
+	Retry     *Retry            `yaml:"retry,omitempty"`      // default para todos los requests
+	RateLimit *RateLimit        `yaml:"rate_limit,omitempty"` // total de requests/s del escenario
+	Capture   *Capture          `yaml:"capture,omitempty"`
+	Events    *EventDelivery    `yaml:"events,omitempty"`
+	Auth      *Auth             `yaml:"auth,omitempty"`
+	Resources *Resources        `yaml:"resources,omitempty"` // default para requests HTML
+	Cache     *Cache            `yaml:"cache,omitempty"`     // caché de navegador por VU
+	Network   *Network          `yaml:"network,omitempty"`   // emulación 3G/4G
+	DNS       *DNS              `yaml:"dns,omitempty"`
+	Proxy     *Proxy            `yaml:"proxy,omitempty"`
+	ThinkTime *ThinkTime        `yaml:"think_time,omitempty"` // default para todos los requests
+	OnError   string            `yaml:"on_error,omitempty"`   // default para todos los requests
+	Abort     *Abort            `yaml:"abort,omitempty"`      // corta el run ante errores sostenidos
+	Requests  []Request         `yaml:"requests"`
 }
 
 type ScenarioFile struct {
@@ -59,62 +108,205 @@ type Event struct {
 	Status      int       `json:"status"`
 	LatencyMs   float64   `json:"latency_ms"`
 	Err         string    `json:"err,omitempty"`
+	ErrCode     string    `json:"err_code,omitempty"`
+	Retries     int       `json:"retries,omitempty"`
+	RetryMs     float64   `json:"retry_ms,omitempty"` // con reintentos: tiempo total de todos los intentos
 	Concurrency int       `json:"concurrency"`
+	Dropped     int       `json:"dropped,omitempty"` // solo en EVENT_STATS
+	WarmUp      bool      `json:"warm_up,omitempty"` // request enviado durante el warm-up
 }
 
 // -------------------------------------------------------------
 // Tipos internos para métricas agregadas
 // -------------------------------------------------------------
 
+// runTotals: contadores globales del run (fuera de las métricas por request)
+type runTotals struct {
+	iterations    int64
+	interrupted   int64
+	skipped       int64 // iteraciones cortadas por on_error
+	stoppedVUs    int64 // VUs retirados por on_error: stop_vu
+	aborted       string
+	warmUp        time.Duration
+	warmUpSamples int64 // requests del warm-up, fuera de las métricas
+	eventsPolicy  string
+	eventsDropped int64
+	sources       []*sourceAddr // network.source_ips, con sus contadores
+	annotations   []annotation  // cambios hechos en vivo vía Control
+}
+
 type requestStat struct {
-	name      string
-	latencies []time.Duration
-	failures  int
+	name        string
+	latencies   []time.Duration
+	failures    int
+	retries     int
+	retried     int                     // muestras que necesitaron reintentos
+	retryTime   time.Duration           // suma de su tiempo total, backoff incluido
+	errors      map[string]*errorBucket // código -> conteo y mensajes
+	sent        int                     // mensajes (websocket, etc.)
+	received    int
+	cacheHits   int             // servidos desde la caché del VU, sin muestra de latencia
+	notModified int             // respuestas 304
+	corrected   []time.Duration // latencias corregidas (coordinated omission)
+}
+
+// result: resultado final de un request (tras los reintentos)
+type result struct {
+	name    string
+	method  string
+	path    string
+	status  int
+	latency time.Duration
+	retries int
+	err     error
+	code    string // ver classifyError
+
+	// Con reintentos latency es la del último intento; retryTime va desde
+	// el primero hasta el fin del último, con backoff y rate_limit
+	retryTime time.Duration
+
+	sent     int // mensajes enviados/recibidos en steps no HTTP
+	received int
+	cacheHit bool      // no salió a la red
+	sentAt   time.Time // inicio del step en el VU (antes de reintentos)
+	warmUp   bool      // enviado durante el warm-up: solo va a Events
+
+	// Latencias desde la hora de envío prevista (rate_limit): la propia y
+	// las de los envíos omitidos mientras el VU esperaba
+	corrected []time.Duration
+}
+
+// vu: estado propio de cada usuario virtual
+type vu struct {
+	client        *http.Client
+	cache         *httpCache
+	nextIteration time.Time   // pacing: inicio previsto de la próxima iteración
+	retired       atomic.Bool // bajado por Control: termina tras la iteración en curso
 }
 
 // -------------------------------------------------------------
 // API pública
 // -------------------------------------------------------------
 
+// Options: ajustes del run que pisan a los del YAML (flags del CLI)
+type Options struct {
+	ProxyURL string   // --proxy
+	NoProxy  []string // --no-proxy
+	Control  *Control // ajuste en vivo (VUs, rate, pausa); nil = sin control
+
+	quiet bool // sin cabecera ni resumen impreso (modos que encadenan runs)
+}
+
+// Summary: métricas agregadas de un run completo
+type Summary struct {
+	Requests    int
+	Failures    int
+	ErrorRate   float64 // %
+	RPS         float64 // requests/s sobre la duración real
+	AvgMs       float64
+	P95Ms       float64
+	P99Ms       float64
+	Iterations  int64
+	Elapsed     time.Duration
+	Aborted     string       // motivo si una condición de abort cortó el run
+	Annotations []Annotation // cambios hechos en vivo vía Control
+}
+
+// Annotation: cambio hecho durante el run, con su momento relativo al inicio
+type Annotation struct {
+	AtS  float64 `json:"at_s"`
+	Text string  `json:"text"`
+}
+
 // Run: versión clásica (sin eventos)
 func Run(path string) error {
-	return runInternal(path, nil)
+	_, err := runInternal(path, Options{}, nil)
+	return err
 }
 
 // RunWithEvents: igual que Run pero emite un Event por request completado
 func RunWithEvents(path string, events chan<- Event) error {
-	return runInternal(path, events)
+	_, err := runInternal(path, Options{}, events)
+	return err
+}
+
+// RunWithOptions: Run con ajustes externos; events puede ser nil. Devuelve
+// el resumen aun si el run fue abortado.
+func RunWithOptions(path string, opts Options, events chan<- Event) (*Summary, error) {
+	return runInternal(path, opts, events)
+}
+
+// apply vuelca las opciones sobre el escenario leído del YAML
+func (o Options) apply(scenario *Scenario) {
+	if o.ProxyURL == "" && o.NoProxy == nil {
+		return
+	}
+	proxy := Proxy{}
+	if scenario.Proxy != nil {
+		proxy = *scenario.Proxy
+	}
+	if o.ProxyURL != "" {
+		proxy = Proxy{URL: o.ProxyURL, NoProxy: proxy.NoProxy}
+	}
+	if o.NoProxy != nil {
+		proxy.NoProxy = o.NoProxy
+	}
+	scenario.Proxy = &proxy
 }
 
 // -------------------------------------------------------------
 // Implementación principal
 // -------------------------------------------------------------
 
-func runInternal(path string, events chan<- Event) error {
+func runInternal(path string, opts Options, events chan<- Event) (*Summary, error) {
+	scenario, err := loadScenario(path)
+	if err != nil {
+		return nil, err
+	}
+	summary, err := runScenario(scenario, opts, events)
+	if err != nil {
+		return nil, err
+	}
+	if summary.Aborted != "" {
+		return summary, fmt.Errorf("run aborted: %s", summary.Aborted)
+	}
+	return summary, nil
+}
+
+// loadScenario lee el primer escenario del YAML
+func loadScenario(path string) (Scenario, error) {
 	data, err := os.ReadFile(path)
 	if err != nil {
-		return fmt.Errorf("cannot read YAML file: %v", err)
+		return Scenario{}, fmt.Errorf("cannot read YAML file: %v", err)
 	}
 
 	var file ScenarioFile
 	if err := yaml.Unmarshal(data, &file); err != nil {
-		return fmt.Errorf("invalid YAML format: %v", err)
+		return Scenario{}, fmt.Errorf("invalid YAML format: %v", err)
 	}
 
 	if len(file.Scenarios) == 0 {
-		return fmt.Errorf("no scenarios found in YAML")
+		return Scenario{}, fmt.Errorf("no scenarios found in YAML")
 	}
+	return file.Scenarios[0], nil
+}
 
-	scenario := file.Scenarios[0]
+func runScenario(scenario Scenario, opts Options, events chan<- Event) (*Summary, error) {
+	opts.apply(&scenario)
 	profile := scenario.Profile
 
-	fmt.Printf("🚀 Running scenario: %s\n", scenario.Name)
-	fmt.Printf("Concurrency: %d | Duration: %s | Ramp-up: %s\n",
-		profile.Concurrency, profile.Duration, profile.RampUp)
+	if !opts.quiet {
+		fmt.Printf("🚀 Running scenario: %s\n", scenario.Name)
+		fmt.Printf("Concurrency: %d | Duration: %s | Ramp-up: %s\n",
+			profile.Concurrency, profile.Duration, profile.RampUp)
+		if profile.WarmUp != "" {
+			fmt.Printf("Warm-up: %s (excluded from metrics)\n", profile.WarmUp)
+		}
+	}
 
 	duration, err := time.ParseDuration(profile.Duration)
 	if err != nil {
-		return fmt.Errorf("invalid duration: %v", err)
+		return nil, fmt.Errorf("invalid duration: %v", err)
 	}
 
 	rampUp := time.Duration(0)
@@ -124,18 +316,50 @@ func runInternal(path string, events chan<- Event) error {
 		}
 	}
 
+	// Ventana para que las iteraciones en curso terminen tras `duration`;
+	// al vencer se cancelan vía context
+	gracefulStop := time.Duration(0)
+	if profile.GracefulStop != "" {
+		gs, err := time.ParseDuration(profile.GracefulStop)
+		if err != nil {
+			return nil, fmt.Errorf("invalid graceful_stop: %v", err)
+		}
+		gracefulStop = gs
+	}
+
+	// El warm-up corre antes de duration: misma carga, sin muestras
+	warmUp := time.Duration(0)
+	if profile.WarmUp != "" {
+		wu, err := time.ParseDuration(profile.WarmUp)
+		if err != nil || wu < 0 {
+			return nil, fmt.Errorf("invalid warm_up %q", profile.WarmUp)
+		}
+		warmUp = wu
+	}
+	duration += warmUp
+
 	start := time.Now()
 	stats := make(map[string]*requestStat)
+	results := make(chan result, 10000)
+	totals := &runTotals{warmUp: warmUp}
+
+	ctx, cancel := context.WithDeadline(context.Background(), start.Add(duration+gracefulStop))
+	defer cancel()
 
-	type result struct {
-		name    string
-		method  string
-		path    string
-		status  int
-		latency time.Duration
-		err     error
+	rn, err := newRunner(scenario, results, start)
+	if err != nil {
+		return nil, err
+	}
+
+	sink, err := newEventSink(events, scenario.Events)
+	if err != nil {
+		return nil, err
+	}
+
+	monitor, err := newAbortMonitor(scenario.Abort, start)
+	if err != nil {
+		return nil, err
 	}
-	results := make(chan result, 10000)
 
 	// Cálculo del escalón entre workers para el ramp-up
 	var step time.Duration
@@ -143,108 +367,122 @@ func runInternal(path string, events chan<- Event) error {
 		step = rampUp / time.Duration(profile.Concurrency)
 	}
 
-	var wg sync.WaitGroup
 	var activeUsers int32 = 0
 
-	for i := 0; i < profile.Concurrency; i++ {
-		wg.Add(1)
-		go func(workerIdx int) {
-			defer wg.Done()
-
-			// Ramp-up escalonado
-			if step > 0 {
-				time.Sleep(step * time.Duration(workerIdx))
+	pool := &vuPool{
+		newVU: func() *vu {
+			return &vu{
+				client: &http.Client{Timeout: 15 * time.Second, Transport: rn.transport},
+				cache:  newHTTPCache(scenario.Cache),
 			}
+		},
+	}
+	pool.launch = func(workerIdx int, v *vu, delay time.Duration) {
+		defer pool.wg.Done()
+
+		// Ramp-up escalonado
+		if delay > 0 {
+			time.Sleep(delay)
+		}
+		if v.retired.Load() {
+			return
+		}
+
+		cur := atomic.AddInt32(&activeUsers, 1)
+		defer atomic.AddInt32(&activeUsers, -1)
+		sink.emit(Event{
+			Timestamp:   time.Now(),
+			Name:        "RAMP_PROGRESS",
+			Method:      "SYSTEM",
+			Path:        fmt.Sprintf("Worker #%d started", workerIdx+1),
+			Concurrency: int(cur),
+		})
 
-			cur := atomic.AddInt32(&activeUsers, 1)
-			if events != nil {
-				events <- Event{
-					Timestamp:   time.Now(),
-					Name:        "RAMP_PROGRESS",
-					Method:      "SYSTEM",
-					Path:        fmt.Sprintf("Worker #%d started", workerIdx+1),
-					Concurrency: int(cur),
+		restarts := 0 // restart_iteration seguidos
+		for time.Since(start) < duration && !v.retired.Load() {
+			outcome := rn.runIteration(ctx, v)
+			if outcome != iterRestart {
+				restarts = 0
+			}
+			switch outcome {
+			case iterCompleted:
+				if time.Since(start) >= warmUp {
+					atomic.AddInt64(&totals.iterations, 1)
 				}
+			case iterInterrupted:
+				atomic.AddInt64(&totals.interrupted, 1)
+				return
+			case iterSkipped:
+				atomic.AddInt64(&totals.skipped, 1)
+			case iterRestart:
+				atomic.AddInt64(&totals.skipped, 1)
+				restarts++
+			case iterStopVU:
+				atomic.AddInt64(&totals.skipped, 1)
+				atomic.AddInt64(&totals.stoppedVUs, 1)
+				pool.remove(v)
+				return
 			}
-
-			client := &http.Client{Timeout: 15 * time.Second}
-
-			for time.Since(start) < duration {
-				for _, reqCfg := range scenario.Requests {
-					url := fmt.Sprintf("%s://%s%s", reqCfg.Protocol, reqCfg.Host, reqCfg.Path)
-
-					var body io.Reader
-					if reqCfg.Body != "" {
-						body = bytes.NewBuffer([]byte(reqCfg.Body))
-					}
-
-					req, err := http.NewRequest(reqCfg.Method, url, body)
-					if err != nil {
-						results <- result{
-							name:   fmt.Sprintf("%s %s", reqCfg.Method, reqCfg.Path),
-							method: reqCfg.Method,
-							path:   reqCfg.Path,
-							status: 0,
-							err:    err,
-						}
-						continue
-					}
-
-					for k, v := range reqCfg.Headers {
-						req.Header.Set(k, v)
-					}
-
-					t0 := time.Now()
-					resp, err := client.Do(req)
-					latency := time.Since(t0)
-
-					if err != nil {
-						results <- result{
-							name:    fmt.Sprintf("%s %s", reqCfg.Method, reqCfg.Path),
-							method:  reqCfg.Method,
-							path:    reqCfg.Path,
-							status:  0,
-							latency: latency,
-							err:     err,
-						}
-						continue
-					}
-
-					io.Copy(io.Discard, resp.Body)
-					resp.Body.Close()
-
-					if resp.StatusCode >= 400 {
-						results <- result{
-							name:    fmt.Sprintf("%s %s", reqCfg.Method, reqCfg.Path),
-							method:  reqCfg.Method,
-							path:    reqCfg.Path,
-							status:  resp.StatusCode,
-							latency: latency,
-							err:     fmt.Errorf("status %d", resp.StatusCode),
-						}
-					} else {
-						results <- result{
-							name:    fmt.Sprintf("%s %s", reqCfg.Method, reqCfg.Path),
-							method:  reqCfg.Method,
-							path:    reqCfg.Path,
-							status:  resp.StatusCode,
-							latency: latency,
-						}
-					}
+			// Pacing: la próxima iteración espera su inicio previsto,
+			// si todavía cae dentro del run. Un restart espera además su
+			// backoff, para no martillar un request que falla rápido.
+			wait := time.Until(v.nextIteration)
+			if b := restartBackoff(restarts); b > wait {
+				wait = b
+			}
+			if wait > 0 {
+				if time.Since(start)+wait >= duration || sleepCtx(ctx, wait) != nil {
+					break
 				}
 			}
-		}(i)
+		}
+	}
+
+	// El pool retiene el WaitGroup hasta el fin de duration: hasta entonces
+	// Control puede sumar VUs
+	pool.wg.Add(1)
+	pool.add(profile.Concurrency, step)
+	go func() {
+		sleepCtx(ctx, time.Until(start.Add(duration)))
+		pool.close()
+	}()
+
+	live := &liveRun{rn: rn, pool: pool, sink: sink, start: start}
+	opts.Control.attach(live)
+	if warmUp > 0 {
+		go func() {
+			if sleepCtx(ctx, warmUp) == nil {
+				live.annotate("warm-up finished, measuring")
+			}
+		}()
 	}
 
 	go func() {
-		wg.Wait()
+		pool.wg.Wait()
 		close(results)
 	}()
 
 	// Consumo de resultados
 	for r := range results {
+		// Vale la hora de envío: un request lanzado en el warm-up no se
+		// mide aunque termine después
+		r.warmUp = r.sentAt.Before(start.Add(warmUp))
+		if r.warmUp && r.cacheHit {
+			continue
+		}
+
+		stat, ok := stats[r.name]
+		if !ok && !r.warmUp {
+			stat = &requestStat{name: r.name}
+			stats[r.name] = stat
+		}
+		if r.cacheHit {
+			stat.cacheHits++
+			continue
+		}
+
 		curConc := int(atomic.LoadInt32(&activeUsers))
-		if events != nil {
+		if sink != nil {
 			ev := Event{
 				Timestamp:   time.Now(),
 				Name:        r.name,
@@ -252,48 +490,503 @@ func runInternal(path string, events chan<- Event) error {
 				Path:        r.path,
 				Status:      r.status,
 				LatencyMs:   float64(r.latency.Microseconds()) / 1000.0,
+				Retries:     r.retries,
+				RetryMs:     float64(r.retryTime.Microseconds()) / 1000.0,
 				Concurrency: curConc,
+				WarmUp:      r.warmUp,
 			}
 			if r.err != nil {
 				ev.Err = r.err.Error()
+				ev.ErrCode = r.code
 			}
-			select {
-			case events <- ev:
-			default:
-			}
+			sink.emit(ev)
 		}
 
-		stat, ok := stats[r.name]
-		if !ok {
-			stat = &requestStat{name: r.name}
-			stats[r.name] = stat
+		if reason := monitor.observe(r, time.Now()); reason != "" && totals.aborted == "" {
+			totals.aborted = reason
+			live.annotate("aborted: " + reason)
+			cancel()
+		}
+		if r.warmUp {
+			totals.warmUpSamples++
+			continue
 		}
+
 		stat.latencies = append(stat.latencies, r.latency)
+		stat.corrected = append(stat.corrected, r.corrected...)
+		stat.retries += r.retries
+		if r.retries > 0 {
+			stat.retried++
+			stat.retryTime += r.retryTime
+		}
+		stat.sent += r.sent
+		stat.received += r.received
+		if r.status == http.StatusNotModified {
+			stat.notModified++
+		}
 		if r.err != nil {
 			stat.failures++
+			stat.addError(r.code, r.err)
+		}
+	}
+
+	// Primero se suelta el Control, así no llegan anotaciones con el sink
+	// cerrado
+	opts.Control.detach(live)
+	if sink != nil {
+		sink.close()
+		totals.eventsPolicy = sink.policy
+		totals.eventsDropped = atomic.LoadInt64(&sink.dropped)
+	}
+	totals.sources = rn.dialer.sources
+	live.mu.Lock()
+	totals.annotations = append([]annotation(nil), live.annotations...)
+	live.mu.Unlock()
+
+	summary := newSummary(stats, totals, time.Since(start.Add(warmUp)))
+	if !opts.quiet {
+		if err := summarize(stats, totals); err != nil {
+			return nil, err
+		}
+	}
+	return summary, nil
+}
+
+// -------------------------------------------------------------
+// runner — estado compartido por todos los VUs de un run
+// -------------------------------------------------------------
+
+type runner struct {
+	scenario      Scenario
+	results       chan<- result
+	scenarioLimit atomic.Pointer[limiter] // reemplazable en vivo (Control.SetRate)
+	requestLimits []*limiter              // mismo índice que scenario.Requests
+	capture       *capturer
+	auth          *authenticator
+	wsPatterns    [][]*regexp.Regexp // expects precompilados, por request
+	ssePatterns   []*regexp.Regexp
+	graphql       []Request // requests graphql ya traducidos a POST JSON
+	sockets       []*socketPlan
+	resources     []*resourcePlan
+	dialer        *netDialer // todas las conexiones salientes
+	transport     *http.Transport
+	think         []*thinkPlan
+	onError       []string // política on_error, por request
+	pacing        time.Duration
+	gate          pauseGate // Control.Pause / Resume
+}
+
+func newRunner(scenario Scenario, results chan<- result, start time.Time) (*runner, error) {
+	rn := &runner{
+		scenario:      scenario,
+		results:       results,
+		requestLimits: make([]*limiter, len(scenario.Requests)),
+		wsPatterns:    make([][]*regexp.Regexp, len(scenario.Requests)),
+		ssePatterns:   make([]*regexp.Regexp, len(scenario.Requests)),
+		graphql:       make([]Request, len(scenario.Requests)),
+		sockets:       make([]*socketPlan, len(scenario.Requests)),
+		resources:     make([]*resourcePlan, len(scenario.Requests)),
+		think:         make([]*thinkPlan, len(scenario.Requests)),
+		onError:       make([]string, len(scenario.Requests)),
+	}
+
+	var err error
+	scenarioLimit, err := newLimiter(scenario.RateLimit, start)
+	if err != nil {
+		return nil, err
+	}
+	rn.scenarioLimit.Store(scenarioLimit)
+	if rn.dialer, err = newNetDialer(scenario); err != nil {
+		return nil, err
+	}
+	rn.transport = rn.dialer.transport()
+	if scenario.Profile.Pacing != "" {
+		if rn.pacing, err = time.ParseDuration(scenario.Profile.Pacing); err != nil {
+			return nil, fmt.Errorf("invalid pacing: %v", err)
+		}
+	}
+	for i, reqCfg := range scenario.Requests {
+		if rn.requestLimits[i], err = newLimiter(reqCfg.RateLimit, start); err != nil {
+			return nil, fmt.Errorf("request %q: %v", reqCfg.Name, err)
+		}
+		if err := retryPolicy(scenario, reqCfg).validate(); err != nil {
+			return nil, fmt.Errorf("request %q: %v", reqCfg.Name, err)
+		}
+		if rn.think[i], err = newThinkPlan(scenario, reqCfg); err != nil {
+			return nil, fmt.Errorf("request %q: %v", reqCfg.Name, err)
+		}
+		if rn.onError[i], err = onErrorPolicy(scenario, reqCfg); err != nil {
+			return nil, fmt.Errorf("request %q: %v", reqCfg.Name, err)
+		}
+		switch reqCfg.Type {
+		case "", StepHTTP:
+			if err := checkBody(reqCfg); err != nil {
+				return nil, fmt.Errorf("request %q: %v", reqCfg.Name, err)
+			}
+			if rn.resources[i], err = newResourcePlan(scenario, reqCfg); err != nil {
+				return nil, fmt.Errorf("request %q: %v", reqCfg.Name, err)
+			}
+		case StepWebSocket:
+			if rn.wsPatterns[i], err = compileWSPatterns(reqCfg.WebSocket); err != nil {
+				return nil, fmt.Errorf("request %q: %v", reqCfg.Name, err)
+			}
+		case StepSSE:
+			if rn.ssePatterns[i], err = compileSSEPattern(reqCfg.SSE); err != nil {
+				return nil, fmt.Errorf("request %q: %v", reqCfg.Name, err)
+			}
+		case StepGraphQL:
+			if rn.graphql[i], err = graphqlRequest(reqCfg, scenario.Variables); err != nil {
+				return nil, fmt.Errorf("request %q: %v", reqCfg.Name, err)
+			}
+		case StepTCP, StepUDP:
+			if rn.sockets[i], err = newSocketPlan(reqCfg.Socket); err != nil {
+				return nil, fmt.Errorf("request %q: %v", reqCfg.Name, err)
+			}
+		default:
+			return nil, fmt.Errorf("request %q: unknown type %q", reqCfg.Name, reqCfg.Type)
+		}
+	}
+	if rn.capture, err = newCapturer(scenario.Capture, start); err != nil {
+		return nil, err
+	}
+	if rn.auth, err = newAuthenticator(scenario.Auth, rn.transport); err != nil {
+		return nil, err
+	}
+	return rn, nil
+}
+
+// runIteration ejecuta una pasada completa por scenario.Requests.
+// Si el context vence a mitad de la iteración devuelve iterInterrupted y el
+// request cortado no se registra como muestra; un step fallido aplica su
+// política on_error.
+func (rn *runner) runIteration(ctx context.Context, v *vu) iterOutcome {
+	// La pausa no es demora del servidor: tras un resume el pacing se
+	// agenda desde ahora, y una pausa a mitad de iteración corre el envío
+	// previsto de lo que queda
+	paused, err := rn.gate.wait(ctx)
+	if err != nil {
+		return iterInterrupted
+	}
+	if paused > 0 && !v.nextIteration.IsZero() {
+		v.nextIteration = time.Now()
+	}
+	iter := rn.paceIteration(v)
+	// Think time dormido en la iteración: corre el envío previsto de cada
+	// request. Si por sí solo llena el pacing, el pacing no llega a regir y
+	// la próxima iteración se agenda desde el fin real de esta: la pausa
+	// propia del VU no es demora del servidor.
+	var thought time.Duration
+	if !iter.intended.IsZero() {
+		defer func() {
+			if now := time.Now(); thought >= rn.pacing && now.After(v.nextIteration) {
+				v.nextIteration = now
+			}
+		}()
+	}
+
+	for i, reqCfg := range rn.scenario.Requests {
+		if ctx.Err() != nil {
+			return iterInterrupted
+		}
+		paused, err := rn.gate.wait(ctx)
+		if err != nil {
+			return iterInterrupted
+		}
+		if paused > 0 && !iter.intended.IsZero() {
+			iter.intended = iter.intended.Add(paused)
+			v.nextIteration = v.nextIteration.Add(paused)
+		}
+		scenarioSlot, err := rn.scenarioLimit.Load().Wait(ctx)
+		if err != nil {
+			return iterInterrupted
+		}
+		requestSlot, err := rn.requestLimits[i].Wait(ctx)
+		if err != nil {
+			return iterInterrupted
+		}
+		// El request sale cuando lo permiten ambos limiters: vale el slot más tardío
+		slot := scenarioSlot
+		if requestSlot.intended.After(slot.intended) {
+			slot = requestSlot
+		}
+		lag := time.Since(slot.intended)
+		// Con pacing vale la demora respecto del envío previsto dentro de la
+		// iteración (inicio + think time previo), si es mayor
+		if !iter.intended.IsZero() {
+			planned := iter
+			planned.intended = iter.intended.Add(thought)
+			if iterLag := time.Since(planned.intended); slot.intended.IsZero() || iterLag > lag {
+				slot, lag = planned, iterLag
+			}
+		}
+
+		failed := false
+		sentAt := time.Now()
+		for _, r := range rn.runStep(ctx, v, i, reqCfg) {
+			r.sentAt = sentAt
+			if r.err != nil && ctx.Err() != nil {
+				return iterInterrupted
+			}
+			if r.err != nil {
+				failed = true
+			}
+			if !slot.intended.IsZero() {
+				// Desde el envío previsto hasta el fin: con reintentos, el
+				// tiempo total
+				took := r.latency
+				if r.retryTime > 0 {
+					took = r.retryTime
+				}
+				r.corrected = correctedLatencies(took, lag, slot)
+			}
+			rn.results <- r
+		}
+
+		// El think time va también antes de aplicar on_error: sin él, un
+		// restart contra un error inmediato gira en vacío
+		think := rn.think[i].sample()
+		thought += think
+		if sleepCtx(ctx, think) != nil {
+			return iterInterrupted
+		}
+		if failed {
+			switch rn.onError[i] {
+			case OnErrorSkip:
+				return iterSkipped
+			case OnErrorRestart:
+				return iterRestart
+			case OnErrorStopVU:
+				return iterStopVU
+			}
+		}
+	}
+	return iterCompleted
+}
+
+// correctedLatencies: la latencia medida más la demora respecto del slot
+// previsto, y una muestra por cada slot omitido a continuación
+func correctedLatencies(latency, lag time.Duration, slot sendSlot) []time.Duration {
+	if lag < 0 {
+		lag = 0
+	}
+	out := []time.Duration{latency + lag}
+	for k := 1; k <= slot.omitted; k++ {
+		c := latency + lag - time.Duration(k)*slot.interval
+		if c < latency {
+			c = latency
+		}
+		out = append(out, c)
+	}
+	return out
+}
+
+// runStep despacha según el tipo de step; un step puede producir varias muestras
+func (rn *runner) runStep(ctx context.Context, v *vu, idx int, reqCfg Request) []result {
+	switch reqCfg.Type {
+	case StepWebSocket:
+		return rn.runWebSocket(ctx, idx, reqCfg)
+	case StepSSE:
+		return rn.runSSE(ctx, v, idx, reqCfg)
+	case StepGraphQL:
+		return []result{rn.runGraphQL(ctx, v, idx, reqCfg)}
+	case StepTCP, StepUDP:
+		return rn.runSocket(ctx, idx, reqCfg)
+	default:
+		if rn.resources[idx] != nil {
+			return rn.runPage(ctx, v, idx, reqCfg)
+		}
+		return []result{rn.execute(ctx, v, idx, reqCfg, "", nil)}
+	}
+}
+
+// -------------------------------------------------------------
+// Ejecución de un request (con reintentos)
+// -------------------------------------------------------------
+
+// responseCheck valida el body de una respuesta OK (graphql, etc.). Si
+// match está y rechaza los headers, el body no se lee ni se valida.
+type responseCheck struct {
+	match func(h http.Header) bool
+	check func(body []byte) error
+}
+
+// maxCheckedBody: tope de body que se lee cuando hay un responseCheck
+const maxCheckedBody = 10 << 20
+
+// execute corre un request HTTP con sus reintentos. name vacío usa
+// "METHOD path"; check es opcional. idx es el request del escenario, cuyos
+// limiters aplican a cada reintento (-1 = recurso embebido).
+func (rn *runner) execute(ctx context.Context, v *vu, idx int, reqCfg Request, name string, check *responseCheck) result {
+	policy := retryPolicy(rn.scenario, reqCfg)
+	if name == "" {
+		name = fmt.Sprintf("%s %s", reqCfg.Method, reqCfg.Path)
+	}
+	res := result{
+		name:   name,
+		method: reqCfg.Method,
+		path:   reqCfg.Path,
+	}
+	url := fmt.Sprintf("%s://%s%s", reqCfg.Protocol, reqCfg.Host, reqCfg.Path)
+	if v.cache.fresh(reqCfg.Method, url) {
+		res.cacheHit = true
+		return res
+	}
+
+	// Con reintentos la muestra es el último intento, como la vería el
+	// servidor; el tiempo total queda aparte en retryTime
+	var ex *exchange
+	t0 := time.Now()
+	for attempt := 1; ; attempt++ {
+		if rn.capture != nil {
+			ex = &exchange{limit: rn.capture.maxBody}
+		}
+		res.status, res.latency, res.err = rn.doRequest(ctx, v, reqCfg, url, ex, check)
+		if res.err == nil || !policy.shouldRetry(attempt, res.status, res.err) {
+			break
+		}
+		res.retries++
+		if sleepCtx(ctx, policy.delay(attempt)) != nil {
+			break
+		}
+		// Cada reintento también sale dentro del rate_limit
+		if rn.waitLimits(ctx, idx) != nil {
+			break
+		}
+	}
+
+	if res.retries > 0 {
+		res.retryTime = time.Since(t0)
+	}
+	res.code = classifyError(res.status, res.err)
+	if rn.capture.wants(res.err != nil) && ctx.Err() == nil {
+		rn.capture.save(res, ex)
+	}
+	return res
+}
+
+// doRequest hace un único intento; el body se reconstruye en cada llamada.
+// Si ex no es nil se completa con el request/response para la captura.
+func (rn *runner) doRequest(ctx context.Context, v *vu, reqCfg Request, url string, ex *exchange, check *responseCheck) (int, time.Duration, error) {
+	body, contentType, forceType, err := buildBody(reqCfg)
+	if err != nil {
+		return 0, 0, err
+	}
+	var payload []byte
+	if (ex != nil || rn.auth.needsBody()) && body != nil {
+		payload, _ = io.ReadAll(body)
+		body = bytes.NewReader(payload)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, reqCfg.Method, url, body)
+	if err != nil {
+		return 0, 0, err
+	}
+
+	for k, val := range reqCfg.Headers {
+		req.Header.Set(k, val)
+	}
+	if contentType != "" && (forceType || req.Header.Get("Content-Type") == "") {
+		req.Header.Set("Content-Type", contentType)
+	}
+	if !reqCfg.skipAuth {
+		if err := rn.auth.apply(ctx, req, payload); err != nil {
+			return 0, 0, err
+		}
+	}
+	v.cache.conditional(req, url)
+	if ex != nil {
+		ex.method, ex.url, ex.reqHeader, ex.reqBody = req.Method, url, req.Header, payload
+	}
+
+	t0 := time.Now()
+	resp, err := v.client.Do(req)
+	latency := time.Since(t0)
+	if err != nil {
+		return 0, latency, err
+	}
+
+	v.cache.store(req, url, resp)
+
+	if check != nil && check.match != nil && !check.match(resp.Header) {
+		check = nil
+	}
+	var respBody []byte
+	if check != nil {
+		respBody, _ = io.ReadAll(io.LimitReader(decodedBody(resp), maxCheckedBody))
+	} else if ex != nil {
+		respBody, _ = io.ReadAll(io.LimitReader(resp.Body, int64(ex.limit)))
+	}
+	n, _ := io.Copy(io.Discard, resp.Body)
+	resp.Body.Close()
+
+	if ex != nil {
+		ex.status, ex.respHeader = resp.Status, resp.Header
+		ex.respBody = truncate(respBody, ex.limit)
+		ex.truncated = n > 0 || len(respBody) > ex.limit
+	}
+
+	if resp.StatusCode >= 400 {
+		return resp.StatusCode, latency, fmt.Errorf("status %d", resp.StatusCode)
+	}
+	if check != nil {
+		if err := check.check(respBody); err != nil {
+			return resp.StatusCode, latency, err
 		}
 	}
+	return resp.StatusCode, latency, nil
+}
 
-	return summarize(stats)
+// decodedBody: el body de la respuesta sin Content-Encoding. Si el request
+// trae su propio Accept-Encoding el transport no descomprime, y el
+// responseCheck necesita el contenido, no los bytes comprimidos.
+func decodedBody(resp *http.Response) io.Reader {
+	switch strings.ToLower(strings.TrimSpace(resp.Header.Get("Content-Encoding"))) {
+	case "gzip", "x-gzip":
+		if zr, err := gzip.NewReader(resp.Body); err == nil {
+			return zr
+		}
+	case "deflate":
+		// Suele venir con envoltorio zlib, pero hay servidores que mandan
+		// el stream deflate crudo
+		br := bufio.NewReader(resp.Body)
+		if h, err := br.Peek(2); err == nil && h[0]&0x0f == 8 && (uint16(h[0])<<8|uint16(h[1]))%31 == 0 {
+			if zr, err := zlib.NewReader(br); err == nil {
+				return zr
+			}
+		}
+		return flate.NewReader(br)
+	}
+	return resp.Body
 }
 
 // -------------------------------------------------------------
 // Resumen e impresión
 // -------------------------------------------------------------
 
-func summarize(stats map[string]*requestStat) error {
+func summarize(stats map[string]*requestStat, totals *runTotals) error {
 	if len(stats) == 0 {
 		fmt.Println("No requests executed.")
+		if totals.interrupted > 0 {
+			fmt.Printf("Interrupted Iterations: %d\n", totals.interrupted)
+		}
+		if totals.warmUpSamples > 0 {
+			fmt.Printf("Warm-up: %s (%d requests excluded)\n", totals.warmUp, totals.warmUpSamples)
+		}
+		if totals.aborted != "" {
+			fmt.Printf("⛔ Test aborted: %s\n", totals.aborted)
+		}
 		return nil
 	}
 
 	var globalLatencies []time.Duration
+	var globalCorrected []time.Duration
 	var totalFails int
+	var totalRetries int
 	var totalCount int
 
 	fmt.Println("\n--- PER REQUEST METRICS ---")
-	fmt.Printf("%-30s %-10s %-10s %-10s %-10s %-10s %-10s\n",
-		"Request", "Count", "Fails", "Err(%)", "Avg(ms)", "P90(ms)", "P95(ms)")
+	fmt.Printf("%-30s %-10s %-10s %-10s %-10s %-10s %-10s %-10s\n",
+		"Request", "Count", "Fails", "Err(%)", "Retries", "Avg(ms)", "P90(ms)", "P95(ms)")
 
 	names := make([]string, 0, len(stats))
 	for k := range stats {
@@ -310,6 +1003,7 @@ func summarize(stats map[string]*requestStat) error {
 
 		count := len(s.latencies)
 		totalFails += s.failures
+		totalRetries += s.retries
 		totalCount += count
 
 		avg := avgDuration(s.latencies)
@@ -317,12 +1011,21 @@ func summarize(stats map[string]*requestStat) error {
 		p95 := percentile(s.latencies, 95)
 		errorRate := (float64(s.failures) / float64(count)) * 100
 
-		fmt.Printf("%-30s %-10d %-10d %-10.2f %-10.2f %-10.2f %-10.2f\n",
-			s.name, count, s.failures, errorRate, ms(avg), ms(p90), ms(p95))
+		fmt.Printf("%-30s %-10d %-10d %-10.2f %-10d %-10.2f %-10.2f %-10.2f\n",
+			s.name, count, s.failures, errorRate, s.retries, ms(avg), ms(p90), ms(p95))
 
 		globalLatencies = append(globalLatencies, s.latencies...)
+		globalCorrected = append(globalCorrected, s.corrected...)
 	}
 
+	printErrorBreakdown(names, stats)
+	printMessageMetrics(names, stats)
+	printCacheMetrics(names, stats)
+	printRetryMetrics(names, stats)
+	printCorrectedLatencies(names, stats)
+	printSourceMetrics(totals.sources)
+	printAnnotations(totals.annotations)
+
 	sort.Slice(globalLatencies, func(i, j int) bool { return globalLatencies[i] < globalLatencies[j] })
 	avgGlobal := avgDuration(globalLatencies)
 	p95Global := percentile(globalLatencies, 95)
@@ -330,13 +1033,138 @@ func summarize(stats map[string]*requestStat) error {
 	fmt.Println("\n--- RESULTS ---")
 	fmt.Printf("Total Requests: %d\n", totalCount)
 	fmt.Printf("Failures: %d\n", totalFails)
+	fmt.Printf("Retries: %d\n", totalRetries)
+	fmt.Printf("Iterations: %d completed, %d interrupted\n", totals.iterations, totals.interrupted)
+	if totals.skipped > 0 {
+		fmt.Printf("Iterations Cut by on_error: %d (%d VUs stopped)\n", totals.skipped, totals.stoppedVUs)
+	}
+	if totals.warmUp > 0 {
+		fmt.Printf("Warm-up: %s (%d requests excluded)\n", totals.warmUp, totals.warmUpSamples)
+	}
+	if totals.eventsPolicy != "" {
+		fmt.Printf("Events Dropped: %d (policy: %s)\n", totals.eventsDropped, totals.eventsPolicy)
+	}
 	fmt.Printf("Average Latency: %.2fms\n", ms(avgGlobal))
 	fmt.Printf("P95 Latency: %.2fms\n", ms(p95Global))
+	if len(globalCorrected) > 0 {
+		sort.Slice(globalCorrected, func(i, j int) bool { return globalCorrected[i] < globalCorrected[j] })
+		fmt.Printf("P95 Latency (corrected): %.2fms\n", ms(percentile(globalCorrected, 95)))
+	}
+	if totals.aborted != "" {
+		fmt.Printf("⛔ Test aborted: %s\n", totals.aborted)
+	}
 	fmt.Println("----------------")
 
 	return nil
 }
 
+// newSummary: los totales del run, para quien lo invoca desde código
+func newSummary(stats map[string]*requestStat, totals *runTotals, elapsed time.Duration) *Summary {
+	sum := &Summary{Iterations: totals.iterations, Elapsed: elapsed, Aborted: totals.aborted}
+	for _, a := range totals.annotations {
+		sum.Annotations = append(sum.Annotations, Annotation{AtS: a.at.Seconds(), Text: a.text})
+	}
+	var all []time.Duration
+	for _, s := range stats {
+		sum.Failures += s.failures
+		all = append(all, s.latencies...)
+	}
+	sum.Requests = len(all)
+	if sum.Requests == 0 || elapsed <= 0 {
+		return sum
+	}
+	sort.Slice(all, func(i, j int) bool { return all[i] < all[j] })
+	sum.ErrorRate = float64(sum.Failures) / float64(sum.Requests) * 100
+	sum.RPS = float64(sum.Requests) / elapsed.Seconds()
+	sum.AvgMs = ms(avgDuration(all))
+	sum.P95Ms = ms(percentile(all, 95))
+	sum.P99Ms = ms(percentile(all, 99))
+	return sum
+}
+
+func printMessageMetrics(names []string, stats map[string]*requestStat) {
+	header := false
+	for _, name := range names {
+		s := stats[name]
+		if s.sent == 0 && s.received == 0 {
+			continue
+		}
+		if !header {
+			fmt.Println("\n--- MESSAGE METRICS ---")
+			fmt.Printf("%-30s %-10s %-10s\n", "Request", "Sent", "Received")
+			header = true
+		}
+		fmt.Printf("%-30s %-10d %-10d\n", s.name, s.sent, s.received)
+	}
+}
+
+func printCacheMetrics(names []string, stats map[string]*requestStat) {
+	header := false
+	for _, name := range names {
+		s := stats[name]
+		if s.cacheHits == 0 && s.notModified == 0 {
+			continue
+		}
+		if !header {
+			fmt.Println("\n--- CACHE ---")
+			fmt.Printf("%-30s %-10s %-10s\n", "Request", "Hits", "304s")
+			header = true
+		}
+		fmt.Printf("%-30s %-10d %-10d\n", s.name, s.cacheHits, s.notModified)
+	}
+}
+
+// printRetryMetrics: cuánto tardaron, de punta a punta, las muestras que
+// necesitaron reintentos (la latencia por request es solo la del último)
+func printRetryMetrics(names []string, stats map[string]*requestStat) {
+	header := false
+	for _, name := range names {
+		s := stats[name]
+		if s.retried == 0 {
+			continue
+		}
+		if !header {
+			fmt.Println("\n--- RETRIES ---")
+			fmt.Printf("%-30s %-10s %-10s %-10s\n", "Request", "Retried", "Retries", "AvgTot(ms)")
+			header = true
+		}
+		avg := s.retryTime / time.Duration(s.retried)
+		fmt.Printf("%-30s %-10d %-10d %-10.2f\n", s.name, s.retried, s.retries, ms(avg))
+	}
+}
+
+func printAnnotations(annotations []annotation) {
+	if len(annotations) == 0 {
+		return
+	}
+	fmt.Println("\n--- ANNOTATIONS ---")
+	for _, a := range annotations {
+		fmt.Printf("+%-9s %s\n", fmt.Sprintf("%.1fs", a.at.Seconds()), a.text)
+	}
+}
+
+// printCorrectedLatencies compara percentiles medidos y corregidos por
+// coordinated omission, para los requests con hora de envío prevista
+func printCorrectedLatencies(names []string, stats map[string]*requestStat) {
+	header := false
+	for _, name := range names {
+		s := stats[name]
+		if len(s.corrected) == 0 {
+			continue
+		}
+		if !header {
+			fmt.Println("\n--- LATENCY (RAW vs CORRECTED) ---")
+			fmt.Printf("%-30s %-10s %-10s %-10s %-10s %-10s %-10s\n",
+				"Request", "P50(ms)", "P95(ms)", "P99(ms)", "cP50(ms)", "cP95(ms)", "cP99(ms)")
+			header = true
+		}
+		sort.Slice(s.corrected, func(i, j int) bool { return s.corrected[i] < s.corrected[j] })
+		fmt.Printf("%-30s %-10.2f %-10.2f %-10.2f %-10.2f %-10.2f %-10.2f\n", s.name,
+			ms(percentile(s.latencies, 50)), ms(percentile(s.latencies, 95)), ms(percentile(s.latencies, 99)),
+			ms(percentile(s.corrected, 50)), ms(percentile(s.corrected, 95)), ms(percentile(s.corrected, 99)))
+	}
+}
+
 // -------------------------------------------------------------
 // Helpers
 // -------------------------------------------------------------
@@ -0,0 +1,168 @@
+package engine
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"sort"
+	"strings"
+	"syscall"
+)
+
+// -------------------------------------------------------------
+// Clasificación de errores
+// -------------------------------------------------------------
+//
+// Cada fallo se traduce a un código estable; son los mismos nombres que
+// acepta `retry.errors`.
+
+const (
+	ErrTimeout           = "timeout"
+	ErrDNS               = "dns"
+	ErrConnectionRefused = "connection_refused"
+	ErrConnectionReset   = "connection_reset"
+	ErrAddrUnavailable   = "address_unavailable" // sin puertos efímeros / IP local inválida
+	ErrTLS               = "tls"
+	ErrEOF               = "eof"
+	ErrCanceled          = "canceled"
+	ErrHTTP4xx           = "http_4xx"
+	ErrHTTP5xx           = "http_5xx"
+	ErrAssertion         = "assertion"
+	ErrOther             = "other"
+)
+
+// assertionError: la respuesta llegó pero no cumple lo esperado
+type assertionError struct {
+	msg string
+}
+
+func (e *assertionError) Error() string { return e.msg }
+
+func assertionf(format string, args ...any) error {
+	return &assertionError{msg: fmt.Sprintf(format, args...)}
+}
+
+// classifyError devuelve el código del fallo ("" si no hay error)
+func classifyError(status int, err error) string {
+	if err == nil {
+		return ""
+	}
+
+	var assertErr *assertionError
+	var dnsErr *net.DNSError
+	var netErr net.Error
+	var recordErr tls.RecordHeaderError
+	var certErr *tls.CertificateVerificationError
+	var unknownAuth x509.UnknownAuthorityError
+	var hostErr x509.HostnameError
+	var invalidErr x509.CertificateInvalidError
+
+	switch {
+	case errors.As(err, &assertErr):
+		return ErrAssertion
+	case status >= 500:
+		return ErrHTTP5xx
+	case status >= 400:
+		return ErrHTTP4xx
+	case errors.As(err, &dnsErr):
+		return ErrDNS
+	case errors.Is(err, syscall.ECONNREFUSED):
+		return ErrConnectionRefused
+	case errors.Is(err, syscall.ECONNRESET), errors.Is(err, syscall.EPIPE):
+		return ErrConnectionReset
+	case errors.Is(err, syscall.EADDRNOTAVAIL), errors.Is(err, syscall.EADDRINUSE):
+		return ErrAddrUnavailable
+	case errors.As(err, &recordErr), errors.As(err, &certErr), errors.As(err, &unknownAuth),
+		errors.As(err, &hostErr), errors.As(err, &invalidErr), strings.Contains(err.Error(), "tls: "):
+		return ErrTLS
+	case errors.Is(err, context.DeadlineExceeded), errors.As(err, &netErr) && netErr.Timeout():
+		return ErrTimeout
+	case errors.Is(err, context.Canceled):
+		return ErrCanceled
+	case errors.Is(err, io.EOF), errors.Is(err, io.ErrUnexpectedEOF):
+		return ErrEOF
+	}
+	return ErrOther
+}
+
+// -------------------------------------------------------------
+// Desglose de errores por request (para el resumen)
+// -------------------------------------------------------------
+
+const maxDistinctMessages = 50
+
+type errorBucket struct {
+	count    int
+	messages map[string]int
+}
+
+func (s *requestStat) addError(code string, err error) {
+	if s.errors == nil {
+		s.errors = make(map[string]*errorBucket)
+	}
+	b, ok := s.errors[code]
+	if !ok {
+		b = &errorBucket{messages: make(map[string]int)}
+		s.errors[code] = b
+	}
+	b.count++
+
+	msg := err.Error()
+	if _, seen := b.messages[msg]; seen || len(b.messages) < maxDistinctMessages {
+		b.messages[msg]++
+	}
+}
+
+// topMessages: los n mensajes más frecuentes del bucket
+func (b *errorBucket) topMessages(n int) []string {
+	msgs := make([]string, 0, len(b.messages))
+	for m := range b.messages {
+		msgs = append(msgs, m)
+	}
+	sort.Slice(msgs, func(i, j int) bool {
+		if b.messages[msgs[i]] != b.messages[msgs[j]] {
+			return b.messages[msgs[i]] > b.messages[msgs[j]]
+		}
+		return msgs[i] < msgs[j]
+	})
+	if len(msgs) > n {
+		msgs = msgs[:n]
+	}
+	out := make([]string, len(msgs))
+	for i, m := range msgs {
+		out[i] = fmt.Sprintf("%s (x%d)", m, b.messages[m])
+	}
+	return out
+}
+
+func printErrorBreakdown(names []string, stats map[string]*requestStat) {
+	var found bool
+	for _, name := range names {
+		if len(stats[name].errors) > 0 {
+			found = true
+			break
+		}
+	}
+	if !found {
+		return
+	}
+
+	fmt.Println("\n--- ERROR BREAKDOWN ---")
+	fmt.Printf("%-30s %-20s %-10s %s\n", "Request", "Code", "Count", "Top messages")
+	for _, name := range names {
+		s := stats[name]
+		codes := make([]string, 0, len(s.errors))
+		for c := range s.errors {
+			codes = append(codes, c)
+		}
+		sort.Strings(codes)
+		for _, code := range codes {
+			b := s.errors[code]
+			fmt.Printf("%-30s %-20s %-10d %s\n", s.name, code, b.count, strings.Join(b.topMessages(3), "; "))
+		}
+	}
+}
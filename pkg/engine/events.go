@@ -0,0 +1,189 @@
+package engine
+
+import (
+	"fmt"
+	"math/rand"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// -------------------------------------------------------------
+// Entrega de eventos al consumidor (SSE / orquestador)
+// -------------------------------------------------------------
+//
+//	events:
+//	  policy: drop_oldest  # drop_oldest (default) | block | sample
+//	  buffer: 1000         # cola interna para drop_oldest
+//	  sample: 0.1          # fracción de eventos de request a enviar (sample)
+//
+// drop_oldest descarta los eventos de request más viejos de la cola, así un
+// consumidor lento no frena a los VUs; block nunca pierde eventos pero frena
+// al engine y sample envía solo una fracción. Los eventos SYSTEM siempre se
+// entregan. Lo descartado se cuenta y se informa en el resumen y en un evento
+// final EVENT_STATS.
+
+const (
+	PolicyBlock      = "block"
+	PolicyDropOldest = "drop_oldest"
+	PolicySample     = "sample"
+)
+
+type EventDelivery struct {
+	Policy string  `yaml:"policy,omitempty"`
+	Buffer int     `yaml:"buffer,omitempty"`
+	Sample float64 `yaml:"sample,omitempty"`
+}
+
+type eventSink struct {
+	out    chan<- Event
+	policy string
+	sample float64
+
+	mu     sync.Mutex
+	queue  []Event
+	buffer int
+	notify chan struct{}
+	closed bool // tras close, emit no entrega nada
+	done   chan struct{}
+
+	sent    int64
+	dropped int64
+}
+
+func newEventSink(out chan<- Event, cfg *EventDelivery) (*eventSink, error) {
+	if out == nil {
+		return nil, nil
+	}
+	if cfg == nil {
+		cfg = &EventDelivery{}
+	}
+	s := &eventSink{out: out, policy: PolicyDropOldest}
+	if cfg.Policy != "" {
+		s.policy = cfg.Policy
+	}
+
+	switch s.policy {
+	case PolicyBlock:
+	case PolicyDropOldest:
+		s.buffer = 1000
+		if cfg.Buffer > 0 {
+			s.buffer = cfg.Buffer
+		}
+		s.notify = make(chan struct{}, 1)
+		s.done = make(chan struct{})
+		go s.dispatch()
+	case PolicySample:
+		s.sample = cfg.Sample
+		if s.sample <= 0 || s.sample > 1 {
+			return nil, fmt.Errorf("events.sample must be in (0, 1]")
+		}
+	default:
+		return nil, fmt.Errorf("unknown events.policy %q", s.policy)
+	}
+	return s, nil
+}
+
+func (s *eventSink) emit(ev Event) {
+	if s == nil {
+		return
+	}
+	switch s.policy {
+	case PolicyDropOldest:
+		s.mu.Lock()
+		if s.closed {
+			s.mu.Unlock()
+			return
+		}
+		if len(s.queue) >= s.buffer && ev.Method != "SYSTEM" && !s.evictOldest() {
+			// Cola llena solo de SYSTEM: se descarta el nuevo
+			s.mu.Unlock()
+			atomic.AddInt64(&s.dropped, 1)
+			return
+		}
+		s.queue = append(s.queue, ev)
+		s.mu.Unlock()
+		select {
+		case s.notify <- struct{}{}:
+		default:
+		}
+		return
+	case PolicySample:
+		if ev.Method != "SYSTEM" && rand.Float64() >= s.sample {
+			atomic.AddInt64(&s.dropped, 1)
+			return
+		}
+	}
+	// El envío va con s.mu tomado: close espera a los que están en curso
+	// y después ya no entra ninguno al canal
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.closed {
+		return
+	}
+	s.out <- ev
+	atomic.AddInt64(&s.sent, 1)
+}
+
+// evictOldest descarta el evento de request más viejo de la cola; los
+// SYSTEM no se descartan. Se llama con s.mu tomado.
+func (s *eventSink) evictOldest() bool {
+	for i, ev := range s.queue {
+		if ev.Method != "SYSTEM" {
+			s.queue = append(s.queue[:i], s.queue[i+1:]...)
+			atomic.AddInt64(&s.dropped, 1)
+			return true
+		}
+	}
+	return false
+}
+
+// dispatch vacía la cola de drop_oldest hacia el canal del consumidor
+func (s *eventSink) dispatch() {
+	defer close(s.done)
+	for {
+		s.mu.Lock()
+		if len(s.queue) == 0 {
+			closed := s.closed
+			s.mu.Unlock()
+			if closed {
+				return
+			}
+			<-s.notify
+			continue
+		}
+		ev := s.queue[0]
+		s.queue = s.queue[1:]
+		s.mu.Unlock()
+
+		s.out <- ev
+		atomic.AddInt64(&s.sent, 1)
+	}
+}
+
+// close entrega lo pendiente y publica EVENT_STATS. No cierra el canal del
+// consumidor: eso sigue siendo responsabilidad de quien lo creó.
+func (s *eventSink) close() {
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	s.closed = true
+	s.mu.Unlock()
+	if s.policy == PolicyDropOldest {
+		select {
+		case s.notify <- struct{}{}:
+		default:
+		}
+		<-s.done
+	}
+
+	dropped := atomic.LoadInt64(&s.dropped)
+	s.out <- Event{
+		Timestamp: time.Now(),
+		Name:      "EVENT_STATS",
+		Method:    "SYSTEM",
+		Path:      fmt.Sprintf("policy=%s sent=%d dropped=%d", s.policy, atomic.LoadInt64(&s.sent), dropped),
+		Dropped:   int(dropped),
+	}
+}
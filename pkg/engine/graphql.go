@@ -0,0 +1,132 @@
+package engine
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"regexp"
+)
+
+// -------------------------------------------------------------
+// Step GraphQL
+// -------------------------------------------------------------
+//
+//	- name: get_order
+//	  type: graphql
+//	  protocol: https
+//	  host: api.example.com
+//	  path: /graphql
+//	  graphql:
+//	    query: |
+//	      query GetOrder($id: ID!) { order(id: $id) { id status } }
+//	    operation_name: GetOrder
+//	    variables:
+//	      id: ${order_id}
+//
+// query y los strings de variables admiten ${name}: primero se busca en
+// `variables` del escenario y después en el entorno. Un 200 con `errors`
+// no vacío cuenta como fallo (assertion). La métrica se llama
+// "GQL <operation_name>" en lugar de "POST /graphql".
+
+type GraphQLStep struct {
+	Query         string         `yaml:"query"`
+	OperationName string         `yaml:"operation_name,omitempty"`
+	Variables     map[string]any `yaml:"variables,omitempty"`
+}
+
+// graphqlRequest traduce el step a un POST JSON que ejecuta execute
+func graphqlRequest(reqCfg Request, vars map[string]string) (Request, error) {
+	if reqCfg.GraphQL == nil || reqCfg.GraphQL.Query == "" {
+		return Request{}, fmt.Errorf("graphql step needs a graphql.query")
+	}
+	if reqCfg.Body != "" || reqCfg.JSON != nil || reqCfg.Form != nil || reqCfg.Multipart != nil || reqCfg.BodyFile != "" {
+		return Request{}, fmt.Errorf("graphql step builds its own body: body, json, form, multipart and body_file are not allowed")
+	}
+	gql := reqCfg.GraphQL
+
+	payload := map[string]any{"query": interpolate(gql.Query, vars)}
+	if gql.OperationName != "" {
+		payload["operationName"] = gql.OperationName
+	}
+	if gql.Variables != nil {
+		payload["variables"] = interpolateValue(gql.Variables, vars)
+	}
+	b, err := json.Marshal(payload)
+	if err != nil {
+		return Request{}, fmt.Errorf("cannot encode graphql body: %v", err)
+	}
+
+	out := reqCfg
+	out.Method = http.MethodPost
+	out.Body = string(b)
+	out.Headers = make(map[string]string, len(reqCfg.Headers)+1)
+	for k, v := range reqCfg.Headers {
+		out.Headers[k] = v
+	}
+	out.Headers["Content-Type"] = "application/json"
+	return out, nil
+}
+
+func (rn *runner) runGraphQL(ctx context.Context, v *vu, idx int, reqCfg Request) result {
+	name := fmt.Sprintf("GQL %s", reqCfg.GraphQL.OperationName)
+	if reqCfg.GraphQL.OperationName == "" {
+		name = fmt.Sprintf("GQL %s", reqCfg.Name)
+	}
+	return rn.execute(ctx, v, idx, rn.graphql[idx], name, &responseCheck{check: checkGraphQLErrors})
+}
+
+// checkGraphQLErrors: la spec devuelve 200 aunque la operación falle
+func checkGraphQLErrors(body []byte) error {
+	var resp struct {
+		Errors []struct {
+			Message string `json:"message"`
+		} `json:"errors"`
+	}
+	if err := json.Unmarshal(body, &resp); err != nil {
+		return assertionf("graphql response is not JSON: %v", err)
+	}
+	if len(resp.Errors) > 0 {
+		return assertionf("graphql errors (%d): %s", len(resp.Errors), resp.Errors[0].Message)
+	}
+	return nil
+}
+
+// -------------------------------------------------------------
+// Interpolación ${name}
+// -------------------------------------------------------------
+
+// Solo la forma ${name}: $var suelto es sintaxis de GraphQL y no se toca
+var placeholderRe = regexp.MustCompile(`\$\{([A-Za-z_][A-Za-z0-9_.-]*)\}`)
+
+func interpolate(s string, vars map[string]string) string {
+	return placeholderRe.ReplaceAllStringFunc(s, func(m string) string {
+		key := m[2 : len(m)-1]
+		if v, ok := vars[key]; ok {
+			return v
+		}
+		return os.Getenv(key)
+	})
+}
+
+// interpolateValue recorre mapas y listas del YAML interpolando los strings
+func interpolateValue(v any, vars map[string]string) any {
+	switch t := v.(type) {
+	case string:
+		return interpolate(t, vars)
+	case map[string]any:
+		out := make(map[string]any, len(t))
+		for k, val := range t {
+			out[k] = interpolateValue(val, vars)
+		}
+		return out
+	case []any:
+		out := make([]any, len(t))
+		for i, val := range t {
+			out[i] = interpolateValue(val, vars)
+		}
+		return out
+	}
+	return v
+}
@@ -0,0 +1,332 @@
+package engine
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// -------------------------------------------------------------
+// Red: dialer común a HTTP, WebSocket y TCP/UDP
+// -------------------------------------------------------------
+//
+// Todas las conexiones salientes de un run pasan por netDialer, que
+// aplica la emulación de red del escenario:
+//
+//	network:
+//	  profile: 3g          # slow_3g | 3g | 4g (opcional)
+//	  download: 1.6mbps    # bps, kbps o mbps; sin unidad = kbps
+//	  upload: 750kbps
+//	  latency: 150ms       # demora agregada al abrir cada conexión
+//	  source_ips: [10.0.0.5, 10.0.0.6]   # IPs locales de origen
+//
+// Los límites de ancho de banda son por conexión, como los de un
+// dispositivo real; los valores explícitos pisan a los del profile.
+//
+// Con source_ips las conexiones se reparten en round-robin entre las
+// direcciones locales (cada una tiene su propio rango de puertos
+// efímeros) y el resumen muestra conexiones y fallos por IP de origen.
+
+type Network struct {
+	Profile   string   `yaml:"profile,omitempty"`
+	Download  string   `yaml:"download,omitempty"`
+	Upload    string   `yaml:"upload,omitempty"`
+	Latency   string   `yaml:"latency,omitempty"`
+	SourceIPs []string `yaml:"source_ips,omitempty"`
+}
+
+// Perfiles al estilo de las DevTools de los navegadores
+var networkProfiles = map[string]Network{
+	"slow_3g": {Download: "400kbps", Upload: "400kbps", Latency: "2s"},
+	"3g":      {Download: "1.6mbps", Upload: "750kbps", Latency: "560ms"},
+	"4g":      {Download: "9mbps", Upload: "9mbps", Latency: "170ms"},
+}
+
+type netDialer struct {
+	base     *net.Dialer
+	download float64 // bytes/s, 0 = sin límite
+	upload   float64
+	latency  time.Duration
+	resolver *dnsResolver // nil = resolver del sistema
+	sources  []*sourceAddr
+	next     uint64       // round-robin de sources
+	proxy    *proxyConfig // nil = directo (HTTP: proxy del entorno)
+}
+
+// sourceAddr: IP local de origen y sus contadores de conexión
+type sourceAddr struct {
+	ip       net.IP
+	conns    int64
+	failures int64
+
+	mu    sync.Mutex
+	codes map[string]int
+}
+
+func newNetDialer(scenario Scenario) (*netDialer, error) {
+	d := &netDialer{base: &net.Dialer{Timeout: 15 * time.Second, KeepAlive: 30 * time.Second}}
+	var err error
+	if d.resolver, err = newDNSResolver(scenario.DNS); err != nil {
+		return nil, err
+	}
+	if d.proxy, err = newProxyConfig(scenario.Proxy); err != nil {
+		return nil, err
+	}
+	cfg := scenario.Network
+	if cfg == nil {
+		return d, nil
+	}
+
+	for _, src := range cfg.SourceIPs {
+		ip := net.ParseIP(src)
+		if ip == nil {
+			return nil, fmt.Errorf("invalid network source ip %q", src)
+		}
+		d.sources = append(d.sources, &sourceAddr{ip: ip, codes: make(map[string]int)})
+	}
+
+	eff := Network{}
+	if cfg.Profile != "" {
+		p, ok := networkProfiles[cfg.Profile]
+		if !ok {
+			return nil, fmt.Errorf("unknown network profile %q", cfg.Profile)
+		}
+		eff = p
+	}
+	if cfg.Download != "" {
+		eff.Download = cfg.Download
+	}
+	if cfg.Upload != "" {
+		eff.Upload = cfg.Upload
+	}
+	if cfg.Latency != "" {
+		eff.Latency = cfg.Latency
+	}
+
+	if d.download, err = parseBandwidth(eff.Download); err != nil {
+		return nil, fmt.Errorf("invalid network download: %v", err)
+	}
+	if d.upload, err = parseBandwidth(eff.Upload); err != nil {
+		return nil, fmt.Errorf("invalid network upload: %v", err)
+	}
+	if eff.Latency != "" {
+		if d.latency, err = time.ParseDuration(eff.Latency); err != nil {
+			return nil, fmt.Errorf("invalid network latency: %v", err)
+		}
+	}
+	return d, nil
+}
+
+// DialContext abre la conexión aplicando latencia y límites de ancho de banda
+func (d *netDialer) DialContext(ctx context.Context, network, addr string) (net.Conn, error) {
+	if err := sleepCtx(ctx, d.latency); err != nil {
+		return nil, err
+	}
+	conn, err := d.dial(ctx, network, addr)
+	if err != nil {
+		return nil, err
+	}
+	if d.download <= 0 && d.upload <= 0 {
+		return conn, nil
+	}
+	return &throttledConn{Conn: conn, read: newThrottle(d.download), write: newThrottle(d.upload)}, nil
+}
+
+// dial resuelve con el bloque dns, si lo hay, y prueba las direcciones
+// en orden hasta que una conecta
+func (d *netDialer) dial(ctx context.Context, network, addr string) (net.Conn, error) {
+	src := d.pickSource()
+	host, port, err := net.SplitHostPort(addr)
+	if err != nil || d.resolver == nil || net.ParseIP(host) != nil {
+		return d.dialFrom(ctx, src, network, addr)
+	}
+	addrs, err := d.resolver.lookup(ctx, host)
+	if err != nil {
+		return nil, err
+	}
+	var firstErr error
+	for _, ip := range addrs {
+		conn, err := d.dialFrom(ctx, src, network, net.JoinHostPort(ip, port))
+		if err == nil {
+			return conn, nil
+		}
+		if firstErr == nil {
+			firstErr = err
+		}
+		if ctx.Err() != nil {
+			break
+		}
+	}
+	return nil, firstErr
+}
+
+func (d *netDialer) pickSource() *sourceAddr {
+	if len(d.sources) == 0 {
+		return nil
+	}
+	n := atomic.AddUint64(&d.next, 1) - 1
+	return d.sources[n%uint64(len(d.sources))]
+}
+
+// dialFrom conecta desde la IP local src (nil = la que elija el sistema)
+func (d *netDialer) dialFrom(ctx context.Context, src *sourceAddr, network, addr string) (net.Conn, error) {
+	if src == nil {
+		return d.base.DialContext(ctx, network, addr)
+	}
+	dialer := *d.base
+	switch network {
+	case "udp", "udp4", "udp6":
+		dialer.LocalAddr = &net.UDPAddr{IP: src.ip}
+	default:
+		dialer.LocalAddr = &net.TCPAddr{IP: src.ip}
+	}
+
+	conn, err := dialer.DialContext(ctx, network, addr)
+	atomic.AddInt64(&src.conns, 1)
+	// Las esperas canceladas (el transporte ya no necesita la conexión) no son fallos
+	if code := classifyError(0, err); code != "" && code != ErrCanceled {
+		atomic.AddInt64(&src.failures, 1)
+		src.mu.Lock()
+		src.codes[code]++
+		src.mu.Unlock()
+	}
+	return conn, err
+}
+
+// transport: transporte HTTP del run, con el dialer del escenario
+func (d *netDialer) transport() *http.Transport {
+	t := http.DefaultTransport.(*http.Transport).Clone()
+	t.DialContext = d.DialContext
+	if d.proxy != nil {
+		t.Proxy = d.proxy.proxyFunc
+	}
+	return t
+}
+
+// parseBandwidth devuelve bytes/s a partir de "750kbps", "1.5mbps", "800"
+func parseBandwidth(s string) (float64, error) {
+	s = strings.ToLower(strings.TrimSpace(s))
+	if s == "" {
+		return 0, nil
+	}
+	mult := 1000.0 // sin unidad: kbps
+	for _, u := range []struct {
+		suffix string
+		mult   float64
+	}{{"mbps", 1e6}, {"kbps", 1e3}, {"bps", 1}} {
+		if strings.HasSuffix(s, u.suffix) {
+			s, mult = strings.TrimSpace(strings.TrimSuffix(s, u.suffix)), u.mult
+			break
+		}
+	}
+	v, err := strconv.ParseFloat(s, 64)
+	if err != nil || v < 0 {
+		return 0, fmt.Errorf("bad bandwidth %q", s)
+	}
+	return v * mult / 8, nil
+}
+
+// -------------------------------------------------------------
+// Conexión con ancho de banda limitado
+// -------------------------------------------------------------
+
+type throttle struct {
+	mu    sync.Mutex
+	rate  float64 // bytes/s
+	chunk int     // bytes por Read/Write: ~100ms de tráfico
+	next  time.Time
+}
+
+func newThrottle(rate float64) *throttle {
+	if rate <= 0 {
+		return nil
+	}
+	chunk := int(rate / 10)
+	if chunk < 512 {
+		chunk = 512
+	}
+	return &throttle{rate: rate, chunk: chunk}
+}
+
+// wait descuenta n bytes y duerme lo necesario para respetar rate.
+// El tiempo ocioso no acumula crédito.
+func (t *throttle) wait(n int) {
+	if n <= 0 {
+		return
+	}
+	t.mu.Lock()
+	now := time.Now()
+	if t.next.Before(now) {
+		t.next = now
+	}
+	t.next = t.next.Add(time.Duration(float64(n) / t.rate * float64(time.Second)))
+	d := t.next.Sub(now)
+	t.mu.Unlock()
+	time.Sleep(d)
+}
+
+type throttledConn struct {
+	net.Conn
+	read  *throttle
+	write *throttle
+}
+
+func (c *throttledConn) Read(p []byte) (int, error) {
+	if c.read == nil {
+		return c.Conn.Read(p)
+	}
+	if len(p) > c.read.chunk {
+		p = p[:c.read.chunk]
+	}
+	n, err := c.Conn.Read(p)
+	c.read.wait(n)
+	return n, err
+}
+
+func (c *throttledConn) Write(p []byte) (int, error) {
+	if c.write == nil {
+		return c.Conn.Write(p)
+	}
+	written := 0
+	for len(p) > 0 {
+		part := p
+		if len(part) > c.write.chunk {
+			part = part[:c.write.chunk]
+		}
+		n, err := c.Conn.Write(part)
+		written += n
+		if err != nil {
+			return written, err
+		}
+		c.write.wait(n)
+		p = p[n:]
+	}
+	return written, nil
+}
+
+// -------------------------------------------------------------
+// Resumen por IP de origen
+// -------------------------------------------------------------
+
+func printSourceMetrics(sources []*sourceAddr) {
+	if len(sources) == 0 {
+		return
+	}
+	fmt.Println("\n--- SOURCE IPS ---")
+	fmt.Printf("%-30s %-10s %-10s %s\n", "Source", "Conns", "Fails", "Errors")
+	for _, src := range sources {
+		codes := make([]string, 0, len(src.codes))
+		for c, n := range src.codes {
+			codes = append(codes, fmt.Sprintf("%s=%d", c, n))
+		}
+		sort.Strings(codes)
+		fmt.Printf("%-30s %-10d %-10d %s\n", src.ip, src.conns, src.failures, strings.Join(codes, ", "))
+	}
+}
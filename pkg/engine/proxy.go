@@ -0,0 +1,320 @@
+package engine
+
+import (
+	"bufio"
+	"context"
+	"crypto/tls"
+	"encoding/base64"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// -------------------------------------------------------------
+// Proxy de salida
+// -------------------------------------------------------------
+//
+//	proxy:
+//	  url: http://proxy.corp:3128        # http | https | socks5
+//	  username: loadtest                 # opcional, pisa al de la URL
+//	  password: ${PROXY_PASSWORD}
+//	  no_proxy: [localhost, .internal.corp, 10.0.0.0/8]
+//
+// También se puede indicar con `pulse run --proxy URL --no-proxy lista`,
+// que pisa al bloque del YAML. HTTP usa el proxy del transporte; WebSocket
+// y TCP abren un túnel (CONNECT o SOCKS5). UDP siempre sale directo.
+// Las entradas de no_proxy son "*", IPs, rangos CIDR o dominios (que
+// incluyen sus subdominios). Sin bloque proxy se respetan HTTP(S)_PROXY y
+// NO_PROXY del entorno para HTTP.
+
+type Proxy struct {
+	URL      string   `yaml:"url"`
+	Username string   `yaml:"username,omitempty"`
+	Password string   `yaml:"password,omitempty"`
+	NoProxy  []string `yaml:"no_proxy,omitempty"`
+}
+
+type proxyConfig struct {
+	url     *url.URL
+	all     bool // no_proxy: "*"
+	nets    []*net.IPNet
+	domains []string
+}
+
+func newProxyConfig(cfg *Proxy) (*proxyConfig, error) {
+	if cfg == nil || cfg.URL == "" {
+		return nil, nil
+	}
+	u, err := url.Parse(interpolate(cfg.URL, nil))
+	if err != nil {
+		return nil, fmt.Errorf("invalid proxy url: %v", err)
+	}
+	switch u.Scheme {
+	case "http", "https", "socks5":
+	default:
+		return nil, fmt.Errorf("unsupported proxy scheme %q", u.Scheme)
+	}
+	if u.Host == "" {
+		return nil, fmt.Errorf("invalid proxy url %q: missing host", cfg.URL)
+	}
+	if cfg.Username != "" {
+		u.User = url.UserPassword(interpolate(cfg.Username, nil), interpolate(cfg.Password, nil))
+	}
+
+	p := &proxyConfig{url: u}
+	for _, entry := range cfg.NoProxy {
+		entry = strings.ToLower(strings.TrimSpace(entry))
+		switch {
+		case entry == "":
+		case entry == "*":
+			p.all = true
+		case strings.Contains(entry, "/"):
+			_, n, err := net.ParseCIDR(entry)
+			if err != nil {
+				return nil, fmt.Errorf("invalid no_proxy entry %q: %v", entry, err)
+			}
+			p.nets = append(p.nets, n)
+		default:
+			if ip := net.ParseIP(entry); ip != nil {
+				p.nets = append(p.nets, &net.IPNet{IP: ip, Mask: net.CIDRMask(len(ip)*8, len(ip)*8)})
+			} else {
+				p.domains = append(p.domains, strings.TrimPrefix(entry, "."))
+			}
+		}
+	}
+	return p, nil
+}
+
+// bypass indica si host (sin puerto) sale directo
+func (p *proxyConfig) bypass(host string) bool {
+	if p.all {
+		return true
+	}
+	host = strings.ToLower(host)
+	if ip := net.ParseIP(host); ip != nil {
+		for _, n := range p.nets {
+			if n.Contains(ip) {
+				return true
+			}
+		}
+		return false
+	}
+	for _, d := range p.domains {
+		if host == d || strings.HasSuffix(host, "."+d) {
+			return true
+		}
+	}
+	return false
+}
+
+// proxyFunc: Transport.Proxy del run
+func (p *proxyConfig) proxyFunc(req *http.Request) (*url.URL, error) {
+	if p.bypass(req.URL.Hostname()) {
+		return nil, nil
+	}
+	return p.url, nil
+}
+
+// -------------------------------------------------------------
+// Túneles para WebSocket y TCP
+// -------------------------------------------------------------
+
+// dialTarget conecta con addr, a través del proxy si corresponde
+func (d *netDialer) dialTarget(ctx context.Context, network, addr string) (net.Conn, error) {
+	host, _, err := net.SplitHostPort(addr)
+	if d.proxy == nil || err != nil || network != "tcp" || d.proxy.bypass(host) {
+		return d.DialContext(ctx, network, addr)
+	}
+
+	proxyAddr := d.proxy.url.Host
+	if d.proxy.url.Port() == "" {
+		port := map[string]string{"http": "80", "https": "443", "socks5": "1080"}[d.proxy.url.Scheme]
+		proxyAddr = net.JoinHostPort(d.proxy.url.Hostname(), port)
+	}
+	conn, err := d.DialContext(ctx, "tcp", proxyAddr)
+	if err != nil {
+		return nil, fmt.Errorf("proxy %s: %w", proxyAddr, err)
+	}
+
+	deadline := time.Now().Add(15 * time.Second)
+	if dl, ok := ctx.Deadline(); ok && dl.Before(deadline) {
+		deadline = dl
+	}
+	conn.SetDeadline(deadline)
+
+	if d.proxy.url.Scheme == "https" {
+		tconn := tls.Client(conn, &tls.Config{ServerName: d.proxy.url.Hostname()})
+		if err := tconn.HandshakeContext(ctx); err != nil {
+			conn.Close()
+			return nil, fmt.Errorf("proxy %s: %w", proxyAddr, err)
+		}
+		conn = tconn
+	}
+
+	var tunnel net.Conn
+	if d.proxy.url.Scheme == "socks5" {
+		tunnel, err = d.socks5Connect(ctx, conn, addr)
+	} else {
+		tunnel, err = d.httpConnect(conn, addr)
+	}
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+	conn.SetDeadline(time.Time{})
+	return tunnel, nil
+}
+
+func (d *netDialer) httpConnect(conn net.Conn, addr string) (net.Conn, error) {
+	req := &http.Request{
+		Method: http.MethodConnect,
+		URL:    &url.URL{Opaque: addr},
+		Host:   addr,
+		Header: make(http.Header),
+	}
+	if u := d.proxy.url.User; u != nil {
+		pass, _ := u.Password()
+		req.Header.Set("Proxy-Authorization", "Basic "+base64.StdEncoding.EncodeToString([]byte(u.Username()+":"+pass)))
+	}
+	if err := req.Write(conn); err != nil {
+		return nil, fmt.Errorf("proxy CONNECT %s: %w", addr, err)
+	}
+
+	br := bufio.NewReader(conn)
+	resp, err := http.ReadResponse(br, req)
+	if err != nil {
+		return nil, fmt.Errorf("proxy CONNECT %s: %w", addr, err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("proxy CONNECT %s: %s", addr, resp.Status)
+	}
+	if br.Buffered() > 0 {
+		return &bufferedConn{Conn: conn, br: br}, nil
+	}
+	return conn, nil
+}
+
+var socks5Replies = map[byte]string{
+	1: "general failure",
+	2: "connection not allowed by ruleset",
+	3: "network unreachable",
+	4: "host unreachable",
+	5: "connection refused",
+	6: "TTL expired",
+	7: "command not supported",
+	8: "address type not supported",
+}
+
+// socks5Connect: RFC 1928 (CONNECT) con usuario/clave de RFC 1929
+func (d *netDialer) socks5Connect(ctx context.Context, conn net.Conn, addr string) (net.Conn, error) {
+	host, portStr, _ := net.SplitHostPort(addr)
+	port, err := strconv.Atoi(portStr)
+	if err != nil {
+		return nil, fmt.Errorf("socks5 %s: invalid port", addr)
+	}
+
+	user := d.proxy.url.User
+	methods := []byte{0x00}
+	if user != nil {
+		methods = []byte{0x00, 0x02}
+	}
+	if _, err := conn.Write(append([]byte{5, byte(len(methods))}, methods...)); err != nil {
+		return nil, fmt.Errorf("socks5 %s: %w", addr, err)
+	}
+	buf := make([]byte, 2)
+	if _, err := io.ReadFull(conn, buf); err != nil {
+		return nil, fmt.Errorf("socks5 %s: %w", addr, err)
+	}
+	switch buf[1] {
+	case 0x00:
+	case 0x02:
+		if user == nil {
+			return nil, fmt.Errorf("socks5 %s: proxy requires credentials", addr)
+		}
+		pass, _ := user.Password()
+		msg := []byte{1, byte(len(user.Username()))}
+		msg = append(msg, user.Username()...)
+		msg = append(msg, byte(len(pass)))
+		msg = append(msg, pass...)
+		if _, err := conn.Write(msg); err != nil {
+			return nil, fmt.Errorf("socks5 %s: %w", addr, err)
+		}
+		if _, err := io.ReadFull(conn, buf); err != nil {
+			return nil, fmt.Errorf("socks5 %s: %w", addr, err)
+		}
+		if buf[1] != 0 {
+			return nil, fmt.Errorf("socks5 %s: authentication failed", addr)
+		}
+	default:
+		return nil, fmt.Errorf("socks5 %s: no acceptable auth method", addr)
+	}
+
+	// Los overrides del bloque dns se resuelven acá; el resto, en el proxy
+	if d.resolver != nil && net.ParseIP(host) == nil {
+		if addrs, err := d.resolver.lookup(ctx, host); err == nil && len(addrs) > 0 {
+			host = addrs[0]
+		}
+	}
+	req := []byte{5, 1, 0}
+	if ip := net.ParseIP(host); ip != nil {
+		if ip4 := ip.To4(); ip4 != nil {
+			req = append(append(req, 1), ip4...)
+		} else {
+			req = append(append(req, 4), ip.To16()...)
+		}
+	} else {
+		req = append(append(req, 3, byte(len(host))), host...)
+	}
+	req = binary.BigEndian.AppendUint16(req, uint16(port))
+	if _, err := conn.Write(req); err != nil {
+		return nil, fmt.Errorf("socks5 %s: %w", addr, err)
+	}
+
+	head := make([]byte, 4)
+	if _, err := io.ReadFull(conn, head); err != nil {
+		return nil, fmt.Errorf("socks5 %s: %w", addr, err)
+	}
+	if head[1] != 0 {
+		reason, ok := socks5Replies[head[1]]
+		if !ok {
+			reason = fmt.Sprintf("reply %d", head[1])
+		}
+		return nil, fmt.Errorf("socks5 %s: %s", addr, reason)
+	}
+	var skip int
+	switch head[3] {
+	case 1:
+		skip = net.IPv4len
+	case 4:
+		skip = net.IPv6len
+	case 3:
+		l := make([]byte, 1)
+		if _, err := io.ReadFull(conn, l); err != nil {
+			return nil, fmt.Errorf("socks5 %s: %w", addr, err)
+		}
+		skip = int(l[0])
+	default:
+		return nil, fmt.Errorf("socks5 %s: bad reply address type", addr)
+	}
+	if _, err := io.ReadFull(conn, make([]byte, skip+2)); err != nil {
+		return nil, fmt.Errorf("socks5 %s: %w", addr, err)
+	}
+	return conn, nil
+}
+
+// bufferedConn: conexión con bytes ya leídos por el bufio del handshake
+type bufferedConn struct {
+	net.Conn
+	br *bufio.Reader
+}
+
+func (c *bufferedConn) Read(p []byte) (int, error) {
+	return c.br.Read(p)
+}
@@ -0,0 +1,215 @@
+package engine
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// -------------------------------------------------------------
+// Rate limit (token bucket compartido entre todos los VUs)
+// -------------------------------------------------------------
+//
+// Se puede declarar a nivel escenario (limita el total de requests/s) o
+// en un request concreto (limita solo ese endpoint):
+//
+//	rate_limit:
+//	  rps: 50
+//	  burst: 5
+//	  schedule:          # opcional: objetivo de rps por tramos
+//	    - duration: 30s
+//	      rps: 10
+//	    - duration: 1m
+//	      rps: 50
+//
+// Tras el último tramo del schedule se mantiene su rps.
+//
+// Además del token bucket, el limiter lleva la agenda ideal de envíos (un
+// slot cada 1/rps). Wait devuelve el slot del request, su hora de envío
+// prevista: si los VUs llegan tarde porque el servidor se trabó, la
+// latencia corregida se mide desde ese slot y no desde el envío real
+// (coordinated omission). Los slots que el bucket ya no va a recuperar se
+// cuentan como omitidos y generan muestras corregidas sintéticas.
+
+type RateLimit struct {
+	RPS      float64     `yaml:"rps,omitempty"`
+	Burst    int         `yaml:"burst,omitempty"`
+	Schedule []RateStage `yaml:"schedule,omitempty"`
+}
+
+type RateStage struct {
+	Duration string  `yaml:"duration"`
+	RPS      float64 `yaml:"rps"`
+}
+
+// sendSlot: hora de envío prevista de un request y slots omitidos tras él
+type sendSlot struct {
+	intended time.Time // cero = sin limiter
+	omitted  int
+	interval time.Duration
+}
+
+type rateStage struct {
+	until time.Duration // offset desde el inicio del run
+	rps   float64
+}
+
+type limiter struct {
+	mu     sync.Mutex
+	start  time.Time
+	rps    float64
+	stages []rateStage
+	burst  float64
+	tokens float64
+	last   time.Time
+	slot   time.Time // próximo envío según la agenda ideal
+}
+
+func newLimiter(cfg *RateLimit, start time.Time) (*limiter, error) {
+	if cfg == nil {
+		return nil, nil
+	}
+	l := &limiter{start: start, rps: cfg.RPS, burst: float64(cfg.Burst), last: start, slot: start}
+	if l.burst < 1 {
+		l.burst = 1
+	}
+
+	var offset time.Duration
+	for i, st := range cfg.Schedule {
+		d, err := time.ParseDuration(st.Duration)
+		if err != nil {
+			return nil, fmt.Errorf("invalid rate_limit schedule[%d] duration: %v", i, err)
+		}
+		offset += d
+		l.stages = append(l.stages, rateStage{until: offset, rps: st.RPS})
+	}
+	if len(l.stages) == 0 && l.rps <= 0 {
+		return nil, fmt.Errorf("rate_limit needs rps or schedule")
+	}
+	l.tokens = l.burst
+	return l, nil
+}
+
+// rate: rps objetivo en el instante now
+func (l *limiter) rate(now time.Time) float64 {
+	if len(l.stages) == 0 {
+		return l.rps
+	}
+	elapsed := now.Sub(l.start)
+	for _, st := range l.stages {
+		if elapsed < st.until {
+			return st.rps
+		}
+	}
+	return l.stages[len(l.stages)-1].rps
+}
+
+// Wait bloquea hasta obtener un token o hasta que venza ctx y devuelve el
+// slot de envío previsto (vacío si no hay limiter).
+// Un rps de 0 en el schedule pausa el tráfico durante ese tramo.
+func (l *limiter) Wait(ctx context.Context) (sendSlot, error) {
+	if l == nil {
+		return sendSlot{}, nil
+	}
+	for {
+		l.mu.Lock()
+		now := time.Now()
+		rate := l.rate(now)
+		if rate <= 0 {
+			l.last = now
+			l.slot = now // la pausa no acumula envíos atrasados
+			l.mu.Unlock()
+			if err := sleepCtx(ctx, 100*time.Millisecond); err != nil {
+				return sendSlot{}, err
+			}
+			continue
+		}
+
+		l.tokens += now.Sub(l.last).Seconds() * rate
+		if l.tokens > l.burst {
+			l.tokens = l.burst
+		}
+		l.last = now
+
+		// Reserva el token aunque quede en negativo: el orden de llegada
+		// determina el orden de salida
+		l.tokens--
+		var wait time.Duration
+		if l.tokens < 0 {
+			wait = time.Duration(-l.tokens / rate * float64(time.Second))
+		}
+		// Agenda ideal: lo que quedó atrás más allá del burst ya no se
+		// recupera; esos slots se omiten
+		slot := sendSlot{intended: l.slot, interval: time.Duration(float64(time.Second) / rate)}
+		next := l.slot.Add(slot.interval)
+		floor := now.Add(-time.Duration(l.burst-1) * slot.interval)
+		if next.Before(floor) {
+			slot.omitted = int(floor.Sub(next) / slot.interval)
+			next = next.Add(time.Duration(slot.omitted) * slot.interval)
+		}
+		l.slot = next
+		l.mu.Unlock()
+
+		return slot, sleepCtx(ctx, wait)
+	}
+}
+
+// currentRate: rps vigente
+func (l *limiter) currentRate(now time.Time) float64 {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.rate(now)
+}
+
+// setRate fija un rps constante, en reemplazo del rps o schedule del YAML
+func (l *limiter) setRate(rps float64, now time.Time) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.rps = rps
+	l.stages = nil
+	if l.slot.Before(now) {
+		l.slot = now
+	}
+}
+
+// resetSchedule reinicia la agenda ideal y el bucket en now (p. ej. al
+// salir de una pausa): la deuda de tokens de antes no se arrastra
+func (l *limiter) resetSchedule(now time.Time) {
+	if l == nil {
+		return
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.slot = now
+	l.last = now
+	l.tokens = l.burst
+}
+
+// waitLimits toma un token del limiter del escenario y del request idx,
+// sin slot para la latencia corregida. idx -1 (recursos embebidos, que no
+// pasan por rate_limit) no espera.
+func (rn *runner) waitLimits(ctx context.Context, idx int) error {
+	if idx < 0 {
+		return nil
+	}
+	if _, err := rn.scenarioLimit.Load().Wait(ctx); err != nil {
+		return err
+	}
+	_, err := rn.requestLimits[idx].Wait(ctx)
+	return err
+}
+
+func sleepCtx(ctx context.Context, d time.Duration) error {
+	if d <= 0 {
+		return ctx.Err()
+	}
+	t := time.NewTimer(d)
+	defer t.Stop()
+	select {
+	case <-t.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
@@ -0,0 +1,206 @@
+package engine
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+)
+
+// -------------------------------------------------------------
+// Recursos embebidos (imágenes, scripts y estilos de una página)
+// -------------------------------------------------------------
+//
+//	resources:
+//	  enabled: true
+//	  parallel: 6           # conexiones simultáneas, como un navegador
+//	  same_host: false      # true: ignora recursos de otros dominios
+//	  exclude: 'analytics|doubleclick'   # regex opcional sobre la URL
+//
+// Se declara por request o como default del escenario. Si la respuesta es
+// HTML se descargan los recursos referenciados y, además de sus muestras
+// individuales, se registra "PAGE <path>": desde el inicio del request
+// principal hasta que termina el último recurso. A otros dominios no se
+// les reenvían las credenciales del bloque auth.
+
+type Resources struct {
+	Enabled  bool   `yaml:"enabled"`
+	Parallel int    `yaml:"parallel,omitempty"`
+	SameHost bool   `yaml:"same_host,omitempty"`
+	Exclude  string `yaml:"exclude,omitempty"`
+}
+
+var (
+	srcAttrRe  = regexp.MustCompile(`(?is)<(?:img|script|iframe|embed|source|input)\b[^>]*?\ssrc\s*=\s*["']?([^"'\s>]+)`)
+	linkTagRe  = regexp.MustCompile(`(?is)<link\b[^>]*>`)
+	relAttrRe  = regexp.MustCompile(`(?is)\srel\s*=\s*["']?([^"'>]+)`)
+	hrefAttrRe = regexp.MustCompile(`(?is)\shref\s*=\s*["']?([^"'\s>]+)`)
+	baseTagRe  = regexp.MustCompile(`(?is)<base\b[^>]*?\shref\s*=\s*["']?([^"'\s>]+)`)
+)
+
+// resourcePlan: config efectiva por request
+type resourcePlan struct {
+	parallel int
+	sameHost bool
+	exclude  *regexp.Regexp
+}
+
+func newResourcePlan(scenario Scenario, reqCfg Request) (*resourcePlan, error) {
+	cfg := reqCfg.Resources
+	if cfg == nil {
+		cfg = scenario.Resources
+	}
+	if cfg == nil || !cfg.Enabled {
+		return nil, nil
+	}
+	p := &resourcePlan{parallel: cfg.Parallel, sameHost: cfg.SameHost}
+	if p.parallel <= 0 {
+		p.parallel = 6
+	}
+	if cfg.Exclude != "" {
+		re, err := regexp.Compile(cfg.Exclude)
+		if err != nil {
+			return nil, fmt.Errorf("invalid resources.exclude: %v", err)
+		}
+		p.exclude = re
+	}
+	return p, nil
+}
+
+// runPage ejecuta el request principal y, si devolvió HTML, sus recursos
+func (rn *runner) runPage(ctx context.Context, v *vu, idx int, reqCfg Request) []result {
+	plan := rn.resources[idx]
+	t0 := time.Now()
+
+	var html []byte
+	main := rn.execute(ctx, v, idx, reqCfg, "", &responseCheck{
+		match: isHTML,
+		check: func(body []byte) error {
+			html = body
+			return nil
+		},
+	})
+	out := []result{main}
+	page := result{name: fmt.Sprintf("PAGE %s", reqCfg.Path), method: reqCfg.Method, path: reqCfg.Path, status: main.status}
+	if main.err != nil {
+		page.latency, page.err, page.code = time.Since(t0), main.err, main.code
+		return append(out, page)
+	}
+
+	if html == nil {
+		// No es HTML: no hay recursos que bajar
+		page.latency = time.Since(t0)
+		return append(out, page)
+	}
+
+	pageURL, err := url.Parse(fmt.Sprintf("%s://%s%s", reqCfg.Protocol, reqCfg.Host, reqCfg.Path))
+	if err != nil {
+		page.latency = time.Since(t0)
+		return append(out, page)
+	}
+	links := plan.extract(pageURL, html)
+
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	var failed int
+	sem := make(chan struct{}, plan.parallel)
+	for _, link := range links {
+		res := Request{
+			Method:   http.MethodGet,
+			Protocol: link.Scheme,
+			Host:     link.Host,
+			Path:     link.RequestURI(),
+			Headers:  resourceHeaders(reqCfg.Headers, pageURL.String()),
+			skipAuth: link.Host != pageURL.Host,
+		}
+		wg.Add(1)
+		sem <- struct{}{}
+		go func() {
+			defer wg.Done()
+			defer func() { <-sem }()
+			r := rn.execute(ctx, v, -1, res, "", nil)
+			mu.Lock()
+			out = append(out, r)
+			if r.err != nil {
+				failed++
+			}
+			mu.Unlock()
+		}()
+	}
+	wg.Wait()
+
+	page.latency = time.Since(t0)
+	page.received = len(links)
+	if failed > 0 {
+		page.err = fmt.Errorf("%d of %d embedded resources failed", failed, len(links))
+		page.code = ErrOther
+	}
+	return append(out, page)
+}
+
+// isHTML: solo se buscan recursos en respuestas text/html
+func isHTML(h http.Header) bool {
+	ct := strings.ToLower(h.Get("Content-Type"))
+	return strings.HasPrefix(ct, "text/html") || strings.HasPrefix(ct, "application/xhtml+xml")
+}
+
+// extract devuelve las URLs absolutas (sin repetir) de los recursos del HTML
+func (p *resourcePlan) extract(pageURL *url.URL, html []byte) []*url.URL {
+	base := pageURL
+	if m := baseTagRe.FindSubmatch(html); m != nil {
+		if u, err := pageURL.Parse(string(m[1])); err == nil {
+			base = u
+		}
+	}
+
+	var refs []string
+	for _, m := range srcAttrRe.FindAllSubmatch(html, -1) {
+		refs = append(refs, string(m[1]))
+	}
+	for _, tag := range linkTagRe.FindAll(html, -1) {
+		rel := relAttrRe.FindSubmatch(tag)
+		href := hrefAttrRe.FindSubmatch(tag)
+		if rel == nil || href == nil {
+			continue
+		}
+		r := strings.ToLower(string(rel[1]))
+		if strings.Contains(r, "stylesheet") || strings.Contains(r, "icon") || strings.Contains(r, "preload") {
+			refs = append(refs, string(href[1]))
+		}
+	}
+
+	seen := make(map[string]bool)
+	var out []*url.URL
+	for _, ref := range refs {
+		ref = strings.ReplaceAll(ref, "&amp;", "&")
+		u, err := base.Parse(ref)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") {
+			continue // data:, javascript:, etc.
+		}
+		u.Fragment = ""
+		key := u.String()
+		if seen[key] || (p.sameHost && u.Host != pageURL.Host) || (p.exclude != nil && p.exclude.MatchString(key)) {
+			continue
+		}
+		seen[key] = true
+		out = append(out, u)
+	}
+	return out
+}
+
+// resourceHeaders: las cabeceras de navegador de la página, sin las propias
+// del documento
+func resourceHeaders(page map[string]string, referer string) map[string]string {
+	out := map[string]string{"Referer": referer, "Accept": "*/*"}
+	for k, v := range page {
+		switch strings.ToLower(k) {
+		case "user-agent", "accept-language", "accept-encoding":
+			out[k] = v
+		}
+	}
+	return out
+}
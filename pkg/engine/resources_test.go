@@ -0,0 +1,91 @@
+package engine
+
+import (
+	"bytes"
+	"compress/gzip"
+	"compress/zlib"
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// pageHTML: página con tres recursos y relleno suficiente para que la
+// compresión achique de verdad el body
+func pageHTML() []byte {
+	var b bytes.Buffer
+	b.WriteString(`<html><head><link rel="stylesheet" href="/static/site.css">`)
+	b.WriteString(`<script src="/static/app.js"></script></head><body>`)
+	for i := 0; i < 500; i++ {
+		b.WriteString("<p>Lorem ipsum dolor sit amet, consectetur adipiscing elit.</p>\n")
+	}
+	b.WriteString(`<img src="/static/logo.png"></body></html>`)
+	return b.Bytes()
+}
+
+func TestRunPageCompressedHTML(t *testing.T) {
+	for _, encoding := range []string{"gzip", "deflate"} {
+		t.Run(encoding, func(t *testing.T) {
+			raw := pageHTML()
+			var compressed bytes.Buffer
+			var w io.WriteCloser
+			if encoding == "gzip" {
+				w = gzip.NewWriter(&compressed)
+			} else {
+				w = zlib.NewWriter(&compressed)
+			}
+			w.Write(raw)
+			w.Close()
+			if compressed.Len() >= len(raw)/4 {
+				t.Fatalf("compressed body %d bytes of %d: fixture does not compress", compressed.Len(), len(raw))
+			}
+
+			var fetched atomic.Int32
+			mux := http.NewServeMux()
+			mux.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+				if !strings.Contains(r.Header.Get("Accept-Encoding"), encoding) {
+					t.Errorf("page request Accept-Encoding = %q", r.Header.Get("Accept-Encoding"))
+				}
+				w.Header().Set("Content-Type", "text/html; charset=utf-8")
+				w.Header().Set("Content-Encoding", encoding)
+				w.Write(compressed.Bytes())
+			})
+			mux.HandleFunc("/static/", func(w http.ResponseWriter, r *http.Request) {
+				fetched.Add(1)
+				w.Write([]byte("x"))
+			})
+			srv := httptest.NewServer(mux)
+			defer srv.Close()
+
+			// Accept-Encoding propio, como en los escenarios grabados: el
+			// transport no descomprime y runPage debe hacerlo
+			req := Request{
+				Name:     "home",
+				Method:   http.MethodGet,
+				Protocol: "http",
+				Host:     strings.TrimPrefix(srv.URL, "http://"),
+				Path:     "/",
+				Headers:  map[string]string{"Accept-Encoding": "gzip, deflate"},
+			}
+			scenario := Scenario{Name: "page", Requests: []Request{req}, Resources: &Resources{Enabled: true}}
+			rn, err := newRunner(scenario, make(chan result, 10), time.Now())
+			if err != nil {
+				t.Fatalf("newRunner: %v", err)
+			}
+			v := &vu{client: &http.Client{Timeout: 5 * time.Second, Transport: rn.transport}, cache: newHTTPCache(nil)}
+
+			out := rn.runPage(context.Background(), v, 0, req)
+			page := out[len(out)-1]
+			if page.err != nil {
+				t.Fatalf("page error: %v", page.err)
+			}
+			if page.received != 3 || fetched.Load() != 3 {
+				t.Fatalf("resources: page reports %d, server saw %d, want 3", page.received, fetched.Load())
+			}
+		})
+	}
+}
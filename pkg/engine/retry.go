@@ -0,0 +1,103 @@
+package engine
+
+import (
+	"fmt"
+	"time"
+)
+
+// -------------------------------------------------------------
+// Política de reintentos (por request o default del escenario)
+// -------------------------------------------------------------
+
+// Retry: bloque `retry` del YAML.
+//
+//	retry:
+//	  max_attempts: 3
+//	  backoff: 200ms
+//	  max_backoff: 2s
+//	  statuses: [502, 503, 504]
+//	  errors: [timeout, connection_reset]   # códigos de errors.go
+type Retry struct {
+	MaxAttempts int      `yaml:"max_attempts"`
+	Backoff     string   `yaml:"backoff,omitempty"`
+	MaxBackoff  string   `yaml:"max_backoff,omitempty"`
+	Statuses    []int    `yaml:"statuses,omitempty"`
+	Errors      []string `yaml:"errors,omitempty"`
+}
+
+var (
+	defaultRetryStatuses = []int{502, 503, 504}
+	defaultRetryErrors   = []string{ErrTimeout, ErrConnectionRefused, ErrConnectionReset, ErrEOF}
+)
+
+// retryPolicy: el bloque del request tiene prioridad sobre el del escenario
+func retryPolicy(scenario Scenario, reqCfg Request) *Retry {
+	if reqCfg.Retry != nil {
+		return reqCfg.Retry
+	}
+	return scenario.Retry
+}
+
+// validate revisa el bloque al cargar el escenario: un backoff mal escrito
+// no debe quedar como "sin espera"
+func (p *Retry) validate() error {
+	if p == nil {
+		return nil
+	}
+	for _, f := range []struct{ name, val string }{{"backoff", p.Backoff}, {"max_backoff", p.MaxBackoff}} {
+		if f.val == "" {
+			continue
+		}
+		if d, err := time.ParseDuration(f.val); err != nil || d < 0 {
+			return fmt.Errorf("invalid retry %s %q (use a duration like 200ms)", f.name, f.val)
+		}
+	}
+	return nil
+}
+
+// shouldRetry decide si el intento `attempt` (1-based) debe repetirse
+func (p *Retry) shouldRetry(attempt, status int, err error) bool {
+	if p == nil || attempt >= p.MaxAttempts {
+		return false
+	}
+
+	if status > 0 {
+		statuses := p.Statuses
+		if len(statuses) == 0 {
+			statuses = defaultRetryStatuses
+		}
+		for _, s := range statuses {
+			if s == status {
+				return true
+			}
+		}
+		return false
+	}
+
+	kinds := p.Errors
+	if len(kinds) == 0 {
+		kinds = defaultRetryErrors
+	}
+	kind := classifyError(0, err)
+	for _, k := range kinds {
+		if k == kind {
+			return true
+		}
+	}
+	return false
+}
+
+// delay: backoff exponencial (backoff * 2^(attempt-1)), acotado por max_backoff
+func (p *Retry) delay(attempt int) time.Duration {
+	base, err := time.ParseDuration(p.Backoff)
+	if err != nil || base <= 0 {
+		return 0
+	}
+	d := base << (attempt - 1)
+	if p.MaxBackoff != "" {
+		if max, err := time.ParseDuration(p.MaxBackoff); err == nil && max > 0 && (d > max || d <= 0) {
+			d = max
+		}
+	}
+	return d
+}
@@ -0,0 +1,184 @@
+package engine
+
+import (
+	"bytes"
+	"context"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"regexp"
+	"time"
+)
+
+// -------------------------------------------------------------
+// Steps TCP / UDP
+// -------------------------------------------------------------
+//
+//	- name: redis_ping
+//	  type: tcp               # tcp | udp
+//	  host: localhost:6379
+//	  socket:
+//	    payload: "PING\r\n"   # o payload_hex: "50494e470d0a"
+//	    read_until: "\r\n"    # delimitador de fin de respuesta
+//	    read_bytes: 0         # o una longitud fija
+//	    timeout: 5s
+//	    expect: '^\+PONG'     # regex opcional sobre la respuesta
+//
+// Sin read_until ni read_bytes se lee hasta que el servidor cierra (TCP),
+// un único datagrama (UDP) o hasta el timeout si ya llegó algo.
+// Métricas: "TCP CONNECT <name>" (solo TCP) y "TCP <name>" / "UDP <name>"
+// desde el envío del payload hasta la respuesta completa.
+
+type SocketStep struct {
+	Payload    string `yaml:"payload,omitempty"`
+	PayloadHex string `yaml:"payload_hex,omitempty"`
+	ReadUntil  string `yaml:"read_until,omitempty"`
+	ReadBytes  int    `yaml:"read_bytes,omitempty"`
+	Timeout    string `yaml:"timeout,omitempty"` // default 10s
+	Expect     string `yaml:"expect,omitempty"`
+}
+
+// socketPlan: step validado y listo para ejecutar
+type socketPlan struct {
+	payload   []byte
+	until     []byte
+	readBytes int
+	timeout   time.Duration
+	expect    *regexp.Regexp
+}
+
+func newSocketPlan(step *SocketStep) (*socketPlan, error) {
+	if step == nil {
+		return nil, fmt.Errorf("tcp/udp step needs a socket block")
+	}
+	p := &socketPlan{
+		payload:   []byte(step.Payload),
+		until:     []byte(step.ReadUntil),
+		readBytes: step.ReadBytes,
+		timeout:   parseTimeout(step.Timeout, 10*time.Second),
+	}
+	if step.PayloadHex != "" {
+		b, err := hex.DecodeString(step.PayloadHex)
+		if err != nil {
+			return nil, fmt.Errorf("invalid socket payload_hex: %v", err)
+		}
+		p.payload = b
+	}
+	if step.Expect != "" {
+		re, err := regexp.Compile(step.Expect)
+		if err != nil {
+			return nil, fmt.Errorf("invalid socket expect %q: %v", step.Expect, err)
+		}
+		p.expect = re
+	}
+	return p, nil
+}
+
+func (rn *runner) runSocket(ctx context.Context, idx int, reqCfg Request) []result {
+	plan := rn.sockets[idx]
+	label := reqCfg.Name
+	if label == "" {
+		label = reqCfg.Host
+	}
+	proto, method := "tcp", "TCP"
+	if reqCfg.Type == StepUDP {
+		proto, method = "udp", "UDP"
+	}
+	exchange := result{name: fmt.Sprintf("%s %s", method, label), method: method, path: reqCfg.Host}
+
+	dialCtx, cancel := context.WithTimeout(ctx, plan.timeout)
+	defer cancel()
+	t0 := time.Now()
+	conn, err := rn.dialer.dialTarget(dialCtx, proto, reqCfg.Host)
+	if err != nil {
+		exchange.err = err
+		if proto == "tcp" {
+			exchange.name = fmt.Sprintf("TCP CONNECT %s", label)
+		}
+		exchange.latency = time.Since(t0)
+		exchange.code = classifyError(0, err)
+		return []result{exchange}
+	}
+	defer conn.Close()
+
+	var out []result
+	if proto == "tcp" {
+		out = append(out, result{name: fmt.Sprintf("TCP CONNECT %s", label), method: method, path: reqCfg.Host, latency: time.Since(t0)})
+	}
+
+	deadline := time.Now().Add(plan.timeout)
+	if dl, ok := ctx.Deadline(); ok && dl.Before(deadline) {
+		deadline = dl
+	}
+	conn.SetDeadline(deadline)
+	// Cancelar el run (abort, fin de duration) corta la espera en el acto
+	stop := context.AfterFunc(ctx, func() { conn.SetDeadline(time.Unix(1, 0)) })
+	defer stop()
+
+	t1 := time.Now()
+	if len(plan.payload) > 0 {
+		if _, err := conn.Write(plan.payload); err != nil {
+			exchange.err = err
+			exchange.latency = time.Since(t1)
+			exchange.code = classifyError(0, err)
+			return append(out, exchange)
+		}
+		exchange.sent = 1
+	}
+
+	reply, err := readReply(conn, proto, plan)
+	exchange.latency = time.Since(t1)
+	if len(reply) > 0 {
+		exchange.received = 1
+	}
+	switch {
+	case ctx.Err() != nil:
+		exchange.err = ctx.Err()
+	case err != nil:
+		exchange.err = err
+	case plan.expect != nil && !plan.expect.Match(reply):
+		exchange.err = assertionf("reply %q does not match %q", truncate(reply, 80), reqCfg.Socket.Expect)
+	}
+	exchange.code = classifyError(0, exchange.err)
+	return append(out, exchange)
+}
+
+// readReply lee hasta el delimitador, la longitud fija o el fin natural
+// de la respuesta (EOF en TCP, un datagrama en UDP)
+func readReply(conn net.Conn, proto string, plan *socketPlan) ([]byte, error) {
+	n := plan.readBytes
+	var buf bytes.Buffer
+	chunk := make([]byte, 64*1024)
+	for {
+		m, err := conn.Read(chunk)
+		buf.Write(chunk[:m])
+
+		switch {
+		case len(plan.until) > 0:
+			if i := bytes.Index(buf.Bytes(), plan.until); i >= 0 {
+				return buf.Bytes()[:i+len(plan.until)], nil
+			}
+		case n > 0:
+			if buf.Len() >= n {
+				return buf.Bytes()[:n], nil
+			}
+		case proto == "udp" && m > 0:
+			return buf.Bytes(), nil
+		}
+
+		if err != nil {
+			var netErr net.Error
+			timedOut := errors.As(err, &netErr) && netErr.Timeout()
+			// Sin delimitador ni longitud, EOF o timeout cierran la respuesta
+			if len(plan.until) == 0 && n == 0 && buf.Len() > 0 && (errors.Is(err, io.EOF) || timedOut) {
+				return buf.Bytes(), nil
+			}
+			if errors.Is(err, io.EOF) {
+				return buf.Bytes(), fmt.Errorf("connection closed before complete reply (%d bytes): %w", buf.Len(), err)
+			}
+			return buf.Bytes(), err
+		}
+	}
+}
@@ -0,0 +1,149 @@
+package engine
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"net/http"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// -------------------------------------------------------------
+// Step SSE (Server-Sent Events)
+// -------------------------------------------------------------
+//
+//	- name: notifications
+//	  type: sse
+//	  protocol: https
+//	  host: api.example.com
+//	  path: /notifications/stream
+//	  sse:
+//	    hold: 30s              # tiempo que el VU mantiene la conexión
+//	    max_events: 0          # corta antes si llega a N eventos (0 = sin tope)
+//	    expect: '"type":"ready"'   # regex opcional sobre el data de algún evento
+//
+// Métricas: "SSE CONNECT <path>" (hasta las cabeceras de respuesta),
+// "SSE FIRST <path>" (hasta el primer evento) y "SSE GAP <path>" (una
+// muestra por intervalo entre eventos). Los eventos recibidos se cuentan
+// en la muestra de CONNECT; si el expect no se cumple en todo el hold, esa
+// muestra falla con código assertion.
+
+type SSEStep struct {
+	Hold      string `yaml:"hold"`
+	MaxEvents int    `yaml:"max_events,omitempty"`
+	Expect    string `yaml:"expect,omitempty"`
+}
+
+func compileSSEPattern(step *SSEStep) (*regexp.Regexp, error) {
+	if step == nil {
+		return nil, fmt.Errorf("sse step needs an sse block")
+	}
+	if _, err := time.ParseDuration(step.Hold); err != nil {
+		return nil, fmt.Errorf("invalid sse hold: %v", err)
+	}
+	if step.Expect == "" {
+		return nil, nil
+	}
+	re, err := regexp.Compile(step.Expect)
+	if err != nil {
+		return nil, fmt.Errorf("invalid sse expect %q: %v", step.Expect, err)
+	}
+	return re, nil
+}
+
+func (rn *runner) runSSE(ctx context.Context, v *vu, idx int, reqCfg Request) []result {
+	hold, _ := time.ParseDuration(reqCfg.SSE.Hold)
+	expect := rn.ssePatterns[idx]
+	url := fmt.Sprintf("%s://%s%s", reqCfg.Protocol, reqCfg.Host, reqCfg.Path)
+	connect := result{name: fmt.Sprintf("SSE CONNECT %s", reqCfg.Path), method: "SSE", path: reqCfg.Path}
+
+	holdCtx, cancel := context.WithTimeout(ctx, hold)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(holdCtx, http.MethodGet, url, nil)
+	if err != nil {
+		connect.err, connect.code = err, classifyError(0, err)
+		return []result{connect}
+	}
+	for k, val := range reqCfg.Headers {
+		req.Header.Set(k, val)
+	}
+	req.Header.Set("Accept", "text/event-stream")
+	req.Header.Set("Cache-Control", "no-cache")
+	if err := rn.auth.apply(ctx, req, nil); err != nil {
+		connect.err, connect.code = err, classifyError(0, err)
+		return []result{connect}
+	}
+
+	// El timeout del cliente incluye la lectura del body: el stream
+	// se acota con hold, no con él
+	streamClient := *v.client
+	streamClient.Timeout = 0
+
+	t0 := time.Now()
+	resp, err := streamClient.Do(req)
+	connect.latency = time.Since(t0)
+	if err != nil {
+		connect.err, connect.code = err, classifyError(0, err)
+		return []result{connect}
+	}
+	defer resp.Body.Close()
+	connect.status = resp.StatusCode
+	if resp.StatusCode >= 400 {
+		connect.err = fmt.Errorf("status %d", resp.StatusCode)
+		connect.code = classifyError(resp.StatusCode, connect.err)
+		return []result{connect}
+	}
+
+	var out []result
+	matched := expect == nil
+	last := t0
+	var data []string
+
+	scanner := bufio.NewScanner(resp.Body)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		line := scanner.Text()
+		switch {
+		case strings.HasPrefix(line, ":"):
+			continue // comentario / keep-alive
+		case strings.HasPrefix(line, "data:"):
+			data = append(data, strings.TrimPrefix(strings.TrimPrefix(line, "data:"), " "))
+			continue
+		case line != "":
+			continue // event:, id:, retry:
+		}
+
+		// línea vacía: fin del evento
+		if data == nil {
+			continue
+		}
+		now := time.Now()
+		name := fmt.Sprintf("SSE GAP %s", reqCfg.Path)
+		if connect.received == 0 {
+			name = fmt.Sprintf("SSE FIRST %s", reqCfg.Path)
+		}
+		out = append(out, result{name: name, method: "SSE", path: reqCfg.Path, status: resp.StatusCode, latency: now.Sub(last)})
+		last = now
+		connect.received++
+
+		if !matched && expect.MatchString(strings.Join(data, "\n")) {
+			matched = true
+		}
+		data = nil
+		if reqCfg.SSE.MaxEvents > 0 && connect.received >= reqCfg.SSE.MaxEvents {
+			break
+		}
+	}
+
+	// Fin del hold: corte esperado. Si cayó el run, runIteration lo descarta.
+	if err := scanner.Err(); err != nil && holdCtx.Err() == nil {
+		connect.err = fmt.Errorf("sse stream: %w", err)
+	} else if !matched {
+		connect.err = assertionf("no sse event matching %q within %s", reqCfg.SSE.Expect, reqCfg.SSE.Hold)
+	}
+	connect.code = classifyError(0, connect.err)
+	return append([]result{connect}, out...)
+}
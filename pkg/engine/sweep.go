@@ -0,0 +1,150 @@
+package engine
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// -------------------------------------------------------------
+// Barrido de escalabilidad (pulse sweep)
+// -------------------------------------------------------------
+//
+//	pulse sweep scenario.yaml --levels 10,50,100,200 --duration 1m --cooldown 15s
+//
+// Corre el mismo escenario en cada nivel de concurrencia, uno tras otro y
+// con una pausa entre runs para que el sistema se estabilice. Imprime una
+// tabla combinada y guarda los datos para graficar throughput y p95 contra
+// la carga en results/sweep_<timestamp>.json (o en --out).
+
+type SweepOptions struct {
+	Options
+
+	Levels   []int         // VUs de cada run
+	Duration time.Duration // por nivel; 0 = profile.duration del escenario
+	Cooldown time.Duration // pausa entre runs
+	Out      string        // JSON de salida; "" = results/sweep_<ts>.json
+}
+
+type SweepPoint struct {
+	Concurrency int     `json:"concurrency"`
+	Requests    int     `json:"requests"`
+	Failures    int     `json:"failures"`
+	ErrorRate   float64 `json:"error_rate"`
+	RPS         float64 `json:"rps"`
+	AvgMs       float64 `json:"avg_ms"`
+	P95Ms       float64 `json:"p95_ms"`
+	P99Ms       float64 `json:"p99_ms"`
+	Aborted     string  `json:"aborted,omitempty"` // motivo si el abort del escenario cortó el nivel
+}
+
+// SweepChart: series listas para graficar, mismo índice que X
+type SweepChart struct {
+	X          []int     `json:"x"` // concurrencia
+	Throughput []float64 `json:"throughput"`
+	P95Ms      []float64 `json:"p95_ms"`
+	ErrorRate  []float64 `json:"error_rate"`
+}
+
+type SweepReport struct {
+	Scenario  string       `json:"scenario"`
+	StartedAt time.Time    `json:"started_at"`
+	EndedAt   time.Time    `json:"ended_at"`
+	Points    []SweepPoint `json:"points"`
+	Chart     SweepChart   `json:"chart"`
+	Path      string       `json:"-"` // archivo escrito
+}
+
+// Sweep corre el escenario en cada nivel y arma el reporte combinado
+func Sweep(path string, opts SweepOptions) (*SweepReport, error) {
+	if len(opts.Levels) == 0 {
+		return nil, fmt.Errorf("sweep needs at least one concurrency level")
+	}
+	for _, l := range opts.Levels {
+		if l <= 0 {
+			return nil, fmt.Errorf("invalid sweep level %d", l)
+		}
+	}
+
+	scenario, err := loadScenario(path)
+	if err != nil {
+		return nil, err
+	}
+	duration := opts.Duration
+	if duration <= 0 {
+		if duration, err = time.ParseDuration(scenario.Profile.Duration); err != nil {
+			return nil, fmt.Errorf("invalid duration: %v", err)
+		}
+	}
+	fmt.Printf("📈 Scalability sweep: %s (levels %v, %s each, %s cool-down)\n",
+		scenario.Name, opts.Levels, duration, opts.Cooldown)
+
+	report := &SweepReport{Scenario: scenario.Name, StartedAt: time.Now()}
+	runOpts := opts.Options
+	runOpts.quiet = true
+	for i, conc := range opts.Levels {
+		if i > 0 && opts.Cooldown > 0 {
+			time.Sleep(opts.Cooldown)
+		}
+		sum, err := runLevel(scenario, runOpts, conc, duration)
+		if err != nil {
+			return nil, err
+		}
+		fmt.Printf("  %4d VUs: %8.1f req/s | p95 %8.2fms | errors %6.2f%%\n", conc, sum.RPS, sum.P95Ms, sum.ErrorRate)
+		if sum.Aborted != "" {
+			fmt.Printf("            ⛔ aborted: %s\n", sum.Aborted)
+		}
+
+		report.Points = append(report.Points, SweepPoint{
+			Concurrency: conc,
+			Requests:    sum.Requests,
+			Failures:    sum.Failures,
+			ErrorRate:   sum.ErrorRate,
+			RPS:         sum.RPS,
+			AvgMs:       sum.AvgMs,
+			P95Ms:       sum.P95Ms,
+			P99Ms:       sum.P99Ms,
+			Aborted:     sum.Aborted,
+		})
+		report.Chart.X = append(report.Chart.X, conc)
+		report.Chart.Throughput = append(report.Chart.Throughput, sum.RPS)
+		report.Chart.P95Ms = append(report.Chart.P95Ms, sum.P95Ms)
+		report.Chart.ErrorRate = append(report.Chart.ErrorRate, sum.ErrorRate)
+	}
+	report.EndedAt = time.Now()
+
+	report.Path = opts.Out
+	if report.Path == "" {
+		report.Path = filepath.Join("results", fmt.Sprintf("sweep_%s.json", report.EndedAt.Format("2006-01-02_150405")))
+	}
+	if err := os.MkdirAll(filepath.Dir(report.Path), 0755); err != nil {
+		return nil, fmt.Errorf("cannot create sweep output dir: %v", err)
+	}
+	data, _ := json.MarshalIndent(report, "", "  ")
+	if err := os.WriteFile(report.Path, data, 0644); err != nil {
+		return nil, fmt.Errorf("cannot write sweep report: %v", err)
+	}
+
+	printSweepReport(report)
+	return report, nil
+}
+
+func printSweepReport(report *SweepReport) {
+	fmt.Println("\n--- SCALABILITY SWEEP ---")
+	fmt.Printf("%-10s %-10s %-10s %-10s %-10s %-10s %-10s\n", "VUs", "Req/s", "Avg(ms)", "P95(ms)", "P99(ms)", "Err(%)", "Scaling")
+
+	// Scaling: throughput relativo al primer nivel, normalizado por VUs
+	// (1.00 = escala lineal)
+	base := report.Points[0]
+	for _, p := range report.Points {
+		scaling := 0.0
+		if base.RPS > 0 {
+			scaling = (p.RPS / base.RPS) / (float64(p.Concurrency) / float64(base.Concurrency))
+		}
+		fmt.Printf("%-10d %-10.1f %-10.2f %-10.2f %-10.2f %-10.2f %-10.2f\n",
+			p.Concurrency, p.RPS, p.AvgMs, p.P95Ms, p.P99Ms, p.ErrorRate, scaling)
+	}
+	fmt.Printf("\nChart data: %s\n", report.Path)
+}
@@ -0,0 +1,130 @@
+package engine
+
+import (
+	"fmt"
+	"math/rand"
+	"time"
+)
+
+// -------------------------------------------------------------
+// Think time y pacing
+// -------------------------------------------------------------
+//
+//	think_time:            # por request o default del escenario
+//	  type: uniform        # fixed | uniform | gaussian | poisson
+//	  duration: 2s         # fixed; media en gaussian y poisson
+//	  min: 1s              # uniform; piso en gaussian
+//	  max: 3s              # uniform; techo en gaussian y poisson
+//	  stddev: 500ms        # gaussian
+//
+//	profile:
+//	  pacing: 10s          # cada iteración dura al menos esto
+//
+// El think time se duerme después de cada request y no entra en las
+// latencias. poisson modela llegadas de un proceso de Poisson: pausas
+// exponenciales con la media indicada. Con pacing, la hora prevista de
+// cada iteración alimenta la latencia corregida (coordinated omission)
+// igual que los slots de rate_limit; el think time del VU corre esa hora
+// y no cuenta como demora.
+
+type ThinkTime struct {
+	Type     string `yaml:"type"`
+	Duration string `yaml:"duration,omitempty"`
+	Min      string `yaml:"min,omitempty"`
+	Max      string `yaml:"max,omitempty"`
+	StdDev   string `yaml:"stddev,omitempty"`
+}
+
+// thinkPlan: think time validado
+type thinkPlan struct {
+	kind     string
+	duration time.Duration
+	min      time.Duration
+	max      time.Duration // 0 = sin techo
+	stddev   time.Duration
+}
+
+func newThinkPlan(scenario Scenario, reqCfg Request) (*thinkPlan, error) {
+	cfg := reqCfg.ThinkTime
+	if cfg == nil {
+		cfg = scenario.ThinkTime
+	}
+	if cfg == nil {
+		return nil, nil
+	}
+
+	p := &thinkPlan{kind: cfg.Type}
+	for _, f := range []struct {
+		name string
+		val  string
+		dst  *time.Duration
+	}{{"duration", cfg.Duration, &p.duration}, {"min", cfg.Min, &p.min}, {"max", cfg.Max, &p.max}, {"stddev", cfg.StdDev, &p.stddev}} {
+		if f.val == "" {
+			continue
+		}
+		d, err := time.ParseDuration(f.val)
+		if err != nil || d < 0 {
+			return nil, fmt.Errorf("invalid think_time %s %q", f.name, f.val)
+		}
+		*f.dst = d
+	}
+
+	switch p.kind {
+	case "fixed", "gaussian", "poisson":
+		if cfg.Duration == "" {
+			return nil, fmt.Errorf("think_time %s needs duration", p.kind)
+		}
+	case "uniform":
+		if cfg.Max == "" || p.max < p.min {
+			return nil, fmt.Errorf("think_time uniform needs min <= max")
+		}
+	default:
+		return nil, fmt.Errorf("unknown think_time type %q", p.kind)
+	}
+	return p, nil
+}
+
+// sample devuelve una pausa según la distribución
+func (p *thinkPlan) sample() time.Duration {
+	if p == nil {
+		return 0
+	}
+	var d time.Duration
+	switch p.kind {
+	case "fixed":
+		return p.duration
+	case "uniform":
+		return p.min + time.Duration(rand.Int63n(int64(p.max-p.min)+1))
+	case "gaussian":
+		d = p.duration + time.Duration(rand.NormFloat64()*float64(p.stddev))
+		if d < p.min {
+			d = p.min
+		}
+	case "poisson":
+		d = time.Duration(rand.ExpFloat64() * float64(p.duration))
+	}
+	if p.max > 0 && d > p.max {
+		d = p.max
+	}
+	return d
+}
+
+// paceIteration reserva el slot de la iteración del VU y agenda la
+// siguiente; las iteraciones que ya no caben se cuentan como omitidas
+func (rn *runner) paceIteration(v *vu) sendSlot {
+	if rn.pacing <= 0 {
+		return sendSlot{}
+	}
+	now := time.Now()
+	if v.nextIteration.IsZero() {
+		v.nextIteration = now
+	}
+	slot := sendSlot{intended: v.nextIteration, interval: rn.pacing}
+	next := v.nextIteration.Add(rn.pacing)
+	if next.Before(now) {
+		slot.omitted = int(now.Sub(next) / rn.pacing)
+		next = next.Add(time.Duration(slot.omitted) * rn.pacing)
+	}
+	v.nextIteration = next
+	return slot
+}
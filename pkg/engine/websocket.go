@@ -0,0 +1,388 @@
+package engine
+
+import (
+	"bufio"
+	"context"
+	"crypto/rand"
+	"crypto/sha1"
+	"crypto/tls"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"regexp"
+	"time"
+)
+
+// -------------------------------------------------------------
+// Step WebSocket
+// -------------------------------------------------------------
+//
+//	- name: chat
+//	  type: websocket
+//	  protocol: wss            # ws | wss (http/https también valen)
+//	  host: chat.example.com
+//	  path: /socket
+//	  websocket:
+//	    actions:
+//	      - send: '{"op":"join","room":"lobby"}'
+//	      - expect: '"op":"joined"'   # regex sobre mensajes de texto
+//	        timeout: 5s
+//	      - send_binary: aGVsbG8=     # base64
+//	      - sleep: 1s
+//
+// Métricas: "WS CONNECT <path>" (handshake) y "WS RECV <path>" (desde el
+// último send hasta el mensaje que cumple el expect), más mensajes
+// enviados/recibidos del step. La conexión se cierra al terminar.
+
+type WebSocketStep struct {
+	Actions []WSAction `yaml:"actions"`
+}
+
+type WSAction struct {
+	Name       string `yaml:"name,omitempty"` // etiqueta para la métrica del expect
+	Send       string `yaml:"send,omitempty"`
+	SendBinary string `yaml:"send_binary,omitempty"`
+	Expect     string `yaml:"expect,omitempty"`
+	Timeout    string `yaml:"timeout,omitempty"` // default 10s
+	Sleep      string `yaml:"sleep,omitempty"`
+}
+
+const (
+	wsOpContinuation = 0x0
+	wsOpText         = 0x1
+	wsOpBinary       = 0x2
+	wsOpClose        = 0x8
+	wsOpPing         = 0x9
+	wsOpPong         = 0xA
+
+	wsGUID = "258EAFA5-E914-47DA-95CA-C5AB0DC85B11"
+)
+
+func (rn *runner) runWebSocket(ctx context.Context, idx int, reqCfg Request) []result {
+	connectName := fmt.Sprintf("WS CONNECT %s", reqCfg.Path)
+	var out []result
+
+	t0 := time.Now()
+	ws, status, err := rn.dialWebSocket(ctx, reqCfg)
+	connect := result{name: connectName, method: "WS", path: reqCfg.Path, status: status, latency: time.Since(t0), err: err}
+	connect.code = classifyError(status, err)
+	if err != nil {
+		return append(out, connect)
+	}
+	defer ws.close(ctx)
+
+	var sent, received int
+	lastSend := time.Now()
+
+actions:
+	for i, act := range reqCfg.WebSocket.Actions {
+		if ctx.Err() != nil {
+			break
+		}
+		switch {
+		case act.Send != "":
+			err = ws.writeFrame(wsOpText, []byte(act.Send))
+			sent++
+			lastSend = time.Now()
+		case act.SendBinary != "":
+			var payload []byte
+			if payload, err = base64.StdEncoding.DecodeString(act.SendBinary); err == nil {
+				err = ws.writeFrame(wsOpBinary, payload)
+				sent++
+				lastSend = time.Now()
+			}
+		case act.Expect != "":
+			name := fmt.Sprintf("WS RECV %s", reqCfg.Path)
+			if act.Name != "" {
+				name = fmt.Sprintf("WS RECV %s", act.Name)
+			}
+			n, rerr := ws.expect(ctx, rn.wsPatterns[idx][i], parseTimeout(act.Timeout, 10*time.Second))
+			received += n
+			r := result{name: name, method: "WS", path: reqCfg.Path, latency: time.Since(lastSend), err: rerr}
+			if rerr != nil {
+				r.err = fmt.Errorf("websocket expect %q: %w", act.Expect, rerr)
+			}
+			r.code = classifyError(0, r.err)
+			out = append(out, r)
+			if rerr != nil {
+				break actions // ya informado en su propia muestra
+			}
+		case act.Sleep != "":
+			_ = sleepCtx(ctx, parseTimeout(act.Sleep, 0))
+		}
+		if err != nil {
+			connect.err = fmt.Errorf("websocket send: %w", err)
+			connect.code = classifyError(0, connect.err)
+			break
+		}
+	}
+
+	connect.sent, connect.received = sent, received
+	return append([]result{connect}, out...)
+}
+
+func parseTimeout(s string, def time.Duration) time.Duration {
+	if d, err := time.ParseDuration(s); err == nil {
+		return d
+	}
+	return def
+}
+
+// compileWSPatterns precompila los expect de cada action
+func compileWSPatterns(step *WebSocketStep) ([]*regexp.Regexp, error) {
+	if step == nil {
+		return nil, fmt.Errorf("websocket step needs a websocket block")
+	}
+	patterns := make([]*regexp.Regexp, len(step.Actions))
+	for i, act := range step.Actions {
+		if act.Expect == "" {
+			continue
+		}
+		re, err := regexp.Compile(act.Expect)
+		if err != nil {
+			return nil, fmt.Errorf("invalid websocket expect %q: %v", act.Expect, err)
+		}
+		patterns[i] = re
+	}
+	return patterns, nil
+}
+
+// -------------------------------------------------------------
+// Cliente RFC 6455 mínimo
+// -------------------------------------------------------------
+
+type wsConn struct {
+	conn net.Conn
+	br   *bufio.Reader
+}
+
+func (rn *runner) dialWebSocket(ctx context.Context, reqCfg Request) (*wsConn, int, error) {
+	secure := reqCfg.Protocol == "wss" || reqCfg.Protocol == "https"
+	httpScheme := "http"
+	if secure {
+		httpScheme = "https"
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, fmt.Sprintf("%s://%s%s", httpScheme, reqCfg.Host, reqCfg.Path), nil)
+	if err != nil {
+		return nil, 0, err
+	}
+	for k, v := range reqCfg.Headers {
+		req.Header.Set(k, v)
+	}
+	keyBytes := make([]byte, 16)
+	rand.Read(keyBytes)
+	key := base64.StdEncoding.EncodeToString(keyBytes)
+	req.Header.Set("Upgrade", "websocket")
+	req.Header.Set("Connection", "Upgrade")
+	req.Header.Set("Sec-WebSocket-Key", key)
+	req.Header.Set("Sec-WebSocket-Version", "13")
+	req.Header.Del("Accept-Encoding")
+	if err := rn.auth.apply(ctx, req, nil); err != nil {
+		return nil, 0, err
+	}
+
+	addr := req.URL.Host
+	if req.URL.Port() == "" {
+		if secure {
+			addr = net.JoinHostPort(req.URL.Hostname(), "443")
+		} else {
+			addr = net.JoinHostPort(req.URL.Hostname(), "80")
+		}
+	}
+
+	conn, err := rn.dialer.dialTarget(ctx, "tcp", addr)
+	if err != nil {
+		return nil, 0, err
+	}
+	if secure {
+		tconn := tls.Client(conn, &tls.Config{ServerName: req.URL.Hostname()})
+		if err := tconn.HandshakeContext(ctx); err != nil {
+			conn.Close()
+			return nil, 0, err
+		}
+		conn = tconn
+	}
+
+	if dl, ok := ctx.Deadline(); ok {
+		conn.SetDeadline(dl)
+	}
+	if err := req.Write(conn); err != nil {
+		conn.Close()
+		return nil, 0, err
+	}
+	br := bufio.NewReader(conn)
+	resp, err := http.ReadResponse(br, req)
+	if err != nil {
+		conn.Close()
+		return nil, 0, err
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusSwitchingProtocols {
+		conn.Close()
+		return nil, resp.StatusCode, fmt.Errorf("websocket handshake status %d", resp.StatusCode)
+	}
+
+	h := sha1.Sum([]byte(key + wsGUID))
+	if resp.Header.Get("Sec-WebSocket-Accept") != base64.StdEncoding.EncodeToString(h[:]) {
+		conn.Close()
+		return nil, resp.StatusCode, fmt.Errorf("websocket handshake: invalid Sec-WebSocket-Accept")
+	}
+	conn.SetDeadline(time.Time{})
+	return &wsConn{conn: conn, br: br}, resp.StatusCode, nil
+}
+
+// writeFrame envía un frame final enmascarado (obligatorio desde el cliente)
+func (c *wsConn) writeFrame(op byte, payload []byte) error {
+	header := []byte{0x80 | op}
+	n := len(payload)
+	switch {
+	case n < 126:
+		header = append(header, 0x80|byte(n))
+	case n <= 0xFFFF:
+		header = append(header, 0x80|126, byte(n>>8), byte(n))
+	default:
+		header = append(header, 0x80|127)
+		header = binary.BigEndian.AppendUint64(header, uint64(n))
+	}
+	mask := make([]byte, 4)
+	rand.Read(mask)
+	header = append(header, mask...)
+
+	masked := make([]byte, n)
+	for i := range payload {
+		masked[i] = payload[i] ^ mask[i%4]
+	}
+	c.conn.SetWriteDeadline(time.Now().Add(10 * time.Second))
+	_, err := c.conn.Write(append(header, masked...))
+	return err
+}
+
+// wsMaxMessage: tope de un mensaje, sumando sus frames de continuación
+const wsMaxMessage = 16 << 20
+
+// readMessage devuelve el siguiente mensaje de datos completo; responde
+// pings y trata el close del servidor como io.EOF. Un mensaje de más de
+// wsMaxMessage es un error: el largo lo manda el servidor.
+func (c *wsConn) readMessage() (byte, []byte, error) {
+	var msgOp byte
+	var msg []byte
+	for {
+		var h [2]byte
+		if _, err := io.ReadFull(c.br, h[:]); err != nil {
+			return 0, nil, err
+		}
+		fin := h[0]&0x80 != 0
+		op := h[0] & 0x0F
+		masked := h[1]&0x80 != 0
+		n := uint64(h[1] & 0x7F)
+		switch n {
+		case 126:
+			var ext [2]byte
+			if _, err := io.ReadFull(c.br, ext[:]); err != nil {
+				return 0, nil, err
+			}
+			n = uint64(binary.BigEndian.Uint16(ext[:]))
+		case 127:
+			var ext [8]byte
+			if _, err := io.ReadFull(c.br, ext[:]); err != nil {
+				return 0, nil, err
+			}
+			n = binary.BigEndian.Uint64(ext[:])
+		}
+		if op >= wsOpClose && n > 125 {
+			return 0, nil, fmt.Errorf("websocket control frame of %d bytes", n)
+		}
+		if n > uint64(wsMaxMessage-len(msg)) {
+			return 0, nil, fmt.Errorf("websocket message exceeds %d bytes", wsMaxMessage)
+		}
+		var mask [4]byte
+		if masked {
+			if _, err := io.ReadFull(c.br, mask[:]); err != nil {
+				return 0, nil, err
+			}
+		}
+		payload := make([]byte, n)
+		if _, err := io.ReadFull(c.br, payload); err != nil {
+			return 0, nil, err
+		}
+		if masked {
+			for i := range payload {
+				payload[i] ^= mask[i%4]
+			}
+		}
+
+		switch op {
+		case wsOpPing:
+			if err := c.writeFrame(wsOpPong, payload); err != nil {
+				return 0, nil, err
+			}
+			continue
+		case wsOpPong:
+			continue
+		case wsOpClose:
+			return 0, nil, io.EOF
+		case wsOpText, wsOpBinary:
+			msgOp = op
+			msg = payload
+		case wsOpContinuation:
+			msg = append(msg, payload...)
+		}
+		if fin {
+			return msgOp, msg, nil
+		}
+	}
+}
+
+// expect lee mensajes hasta que uno de texto cumpla re o venza el timeout.
+// Devuelve cuántos mensajes se recibieron.
+func (c *wsConn) expect(ctx context.Context, re *regexp.Regexp, timeout time.Duration) (int, error) {
+	deadline := time.Now().Add(timeout)
+	if dl, ok := ctx.Deadline(); ok && dl.Before(deadline) {
+		deadline = dl
+	}
+	c.conn.SetReadDeadline(deadline)
+	// Cancelar el run (abort, fin de duration) corta la lectura en el acto
+	stop := context.AfterFunc(ctx, func() { c.conn.SetReadDeadline(time.Unix(1, 0)) })
+	defer func() {
+		stop()
+		c.conn.SetReadDeadline(time.Time{})
+	}()
+
+	received := 0
+	for {
+		op, msg, err := c.readMessage()
+		if err != nil {
+			if ctx.Err() != nil {
+				return received, ctx.Err()
+			}
+			if errors.Is(err, io.EOF) {
+				err = fmt.Errorf("connection closed by server: %w", err)
+			}
+			return received, err
+		}
+		received++
+		if op == wsOpText && re.Match(msg) {
+			return received, nil
+		}
+	}
+}
+
+// close hace el cierre ordenado; con el ctx cancelado no espera la
+// respuesta del servidor
+func (c *wsConn) close(ctx context.Context) {
+	_ = c.writeFrame(wsOpClose, []byte{0x03, 0xE8}) // 1000: normal closure
+	c.conn.SetReadDeadline(time.Now().Add(time.Second))
+	stop := context.AfterFunc(ctx, func() { c.conn.SetReadDeadline(time.Unix(1, 0)) })
+	defer stop()
+	for {
+		if _, _, err := c.readMessage(); err != nil {
+			break
+		}
+	}
+	c.conn.Close()
+}
@@ -0,0 +1,184 @@
+package engine
+
+import (
+	"bufio"
+	"context"
+	"crypto/sha1"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+// wsTestServer acepta el handshake y le pasa la conexión a serve
+func wsTestServer(t *testing.T, serve func(conn net.Conn, br *bufio.Reader)) *httptest.Server {
+	t.Helper()
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		h := sha1.Sum([]byte(r.Header.Get("Sec-WebSocket-Key") + wsGUID))
+		conn, brw, err := w.(http.Hijacker).Hijack()
+		if err != nil {
+			t.Errorf("hijack: %v", err)
+			return
+		}
+		defer conn.Close()
+		io.WriteString(conn, "HTTP/1.1 101 Switching Protocols\r\nUpgrade: websocket\r\nConnection: Upgrade\r\n"+
+			"Sec-WebSocket-Accept: "+base64.StdEncoding.EncodeToString(h[:])+"\r\n\r\n")
+		serve(conn, brw.Reader)
+	}))
+}
+
+// readClientFrame lee un frame (enmascarado, como los manda el cliente)
+func readClientFrame(br *bufio.Reader) (byte, []byte, error) {
+	var h [2]byte
+	if _, err := io.ReadFull(br, h[:]); err != nil {
+		return 0, nil, err
+	}
+	n := uint64(h[1] & 0x7F)
+	switch n {
+	case 126:
+		var ext [2]byte
+		if _, err := io.ReadFull(br, ext[:]); err != nil {
+			return 0, nil, err
+		}
+		n = uint64(binary.BigEndian.Uint16(ext[:]))
+	case 127:
+		var ext [8]byte
+		if _, err := io.ReadFull(br, ext[:]); err != nil {
+			return 0, nil, err
+		}
+		n = binary.BigEndian.Uint64(ext[:])
+	}
+	var mask [4]byte
+	if _, err := io.ReadFull(br, mask[:]); err != nil {
+		return 0, nil, err
+	}
+	payload := make([]byte, n)
+	if _, err := io.ReadFull(br, payload); err != nil {
+		return 0, nil, err
+	}
+	for i := range payload {
+		payload[i] ^= mask[i%4]
+	}
+	return h[0] & 0x0F, payload, nil
+}
+
+// writeServerFrame escribe un frame sin máscara (payload < 126 bytes)
+func writeServerFrame(conn net.Conn, fin bool, op byte, payload []byte) {
+	b0 := op
+	if fin {
+		b0 |= 0x80
+	}
+	conn.Write(append([]byte{b0, byte(len(payload))}, payload...))
+}
+
+func wsTestRunner(t *testing.T, srv *httptest.Server, actions []WSAction) (*runner, Request) {
+	t.Helper()
+	req := Request{
+		Name:      "ws",
+		Type:      StepWebSocket,
+		Protocol:  "ws",
+		Host:      strings.TrimPrefix(srv.URL, "http://"),
+		Path:      "/socket",
+		WebSocket: &WebSocketStep{Actions: actions},
+	}
+	scenario := Scenario{Name: "ws", Requests: []Request{req}}
+	rn, err := newRunner(scenario, make(chan result, 10), time.Now())
+	if err != nil {
+		t.Fatalf("newRunner: %v", err)
+	}
+	return rn, req
+}
+
+func TestWebSocketEcho(t *testing.T) {
+	srv := wsTestServer(t, func(conn net.Conn, br *bufio.Reader) {
+		for {
+			op, payload, err := readClientFrame(br)
+			if err != nil || op == wsOpClose {
+				return
+			}
+			// Ping previo y eco partido en dos frames: el cliente debe
+			// responder el ping y armar el mensaje
+			writeServerFrame(conn, true, wsOpPing, []byte("p"))
+			half := len(payload) / 2
+			writeServerFrame(conn, false, op, payload[:half])
+			writeServerFrame(conn, true, wsOpContinuation, payload[half:])
+		}
+	})
+	defer srv.Close()
+
+	rn, req := wsTestRunner(t, srv, []WSAction{
+		{Send: `{"op":"join","room":"lobby"}`},
+		{Expect: `"room":"lobby"`, Timeout: "2s"},
+	})
+	out := rn.runWebSocket(context.Background(), 0, req)
+
+	if len(out) != 2 {
+		t.Fatalf("expected connect + recv results, got %d", len(out))
+	}
+	connect, recv := out[0], out[1]
+	if connect.err != nil || connect.status != http.StatusSwitchingProtocols {
+		t.Fatalf("connect: status %d, err %v", connect.status, connect.err)
+	}
+	if connect.sent != 1 || connect.received != 1 {
+		t.Errorf("messages: sent %d, received %d; want 1 and 1", connect.sent, connect.received)
+	}
+	if recv.err != nil {
+		t.Errorf("expect failed: %v", recv.err)
+	}
+}
+
+func TestWebSocketRejectsOversizedMessage(t *testing.T) {
+	srv := wsTestServer(t, func(conn net.Conn, br *bufio.Reader) {
+		if _, _, err := readClientFrame(br); err != nil {
+			return
+		}
+		// Frame que anuncia 2^62 bytes: no debe reservarse
+		header := []byte{0x80 | wsOpText, 127}
+		conn.Write(binary.BigEndian.AppendUint64(header, 1<<62))
+		time.Sleep(200 * time.Millisecond)
+	})
+	defer srv.Close()
+
+	rn, req := wsTestRunner(t, srv, []WSAction{
+		{Send: "hi"},
+		{Expect: "never", Timeout: "2s"},
+	})
+	out := rn.runWebSocket(context.Background(), 0, req)
+
+	if len(out) != 2 {
+		t.Fatalf("expected connect + recv results, got %d", len(out))
+	}
+	if err := out[1].err; err == nil || !strings.Contains(err.Error(), "exceeds") {
+		t.Fatalf("expected message size error, got %v", err)
+	}
+}
+
+func TestWebSocketExpectHonoursCancel(t *testing.T) {
+	srv := wsTestServer(t, func(conn net.Conn, br *bufio.Reader) {
+		// Nunca responde ni contesta el close
+		io.Copy(io.Discard, br)
+	})
+	defer srv.Close()
+
+	rn, req := wsTestRunner(t, srv, []WSAction{
+		{Send: "hi"},
+		{Expect: "never", Timeout: "10s"},
+	})
+	ctx, cancel := context.WithCancel(context.Background())
+	time.AfterFunc(100*time.Millisecond, cancel)
+
+	t0 := time.Now()
+	out := rn.runWebSocket(ctx, 0, req)
+	if elapsed := time.Since(t0); elapsed > 2*time.Second {
+		t.Fatalf("runWebSocket took %v after cancel", elapsed)
+	}
+	if len(out) != 2 || !errors.Is(out[1].err, context.Canceled) {
+		t.Fatalf("expected a canceled recv result, got %+v", out)
+	}
+}